@@ -0,0 +1,111 @@
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"os"
+	"time"
+
+	"github.com/dgmorales/go-cli-selfupdate/logger"
+	"github.com/dgmorales/go-cli-selfupdate/start"
+	"github.com/dgmorales/go-cli-selfupdate/version"
+	"github.com/spf13/cobra"
+)
+
+// noticeGrace is how long we wait at exit for a background version check to finish.
+// Past that, we tell the user about the last known result (from a previous run) instead.
+const noticeGrace = 500 * time.Millisecond
+
+// backgroundCheck is a version check running while a command does its work (see
+// --background-check)
+type backgroundCheck struct {
+	cancel context.CancelFunc
+	done   chan struct{}
+	result *version.Result
+	last   *version.Result
+}
+
+// startBackgroundCheck starts checking our version in the background.
+//
+// Blocking version states (like MustUpdate) are still enforced before the command runs,
+// from the last known check result: the version is checked again (as self-update would)
+// and, if that fails, the last known result is enforced as is.
+func startBackgroundCheck(ctx context.Context) *backgroundCheck {
+	// commands set up logging when they run, after us
+	logger.SetUp(flagDebug)
+
+	last, err := start.LastCheck()
+	if err != nil {
+		log.Printf("ignoring last version check result: %s", err)
+	}
+
+	if last != nil && last.Assertion.Blocking() {
+		log.Printf("last version check (at %s) was %d, checking again", last.CheckedAt.Format(time.RFC3339), last.Assertion)
+
+		state, err := start.ForAPIUse(ctx, startOptions())
+		if err == nil && state.Version.Check() != version.IsUnknown {
+			versionCheck(ctx, state.Version)
+			return nil
+		}
+
+		warnVersion(*last)
+		fmt.Println("Cannot continue without updating, and couldn't check the version again. Exiting.")
+		os.Exit(int(last.Assertion))
+	}
+
+	b := backgroundCheck{done: make(chan struct{}), last: last}
+	ctx, b.cancel = context.WithCancel(ctx)
+
+	// we already set up logging, and the command sets up anything else it needs
+	opts := startOptions()
+	opts.Background = true
+
+	go func() {
+		defer close(b.done)
+
+		state, err := start.ForAPIUse(ctx, opts)
+		if err != nil {
+			log.Printf("error checking version in the background: %s", err)
+			return
+		}
+
+		r := version.Snapshot(state.Version)
+		b.result = &r
+	}()
+
+	return &b
+}
+
+// notice tells the user about the background check result, waiting for it for
+// noticeGrace at most. If it is not done by then, it tells about the last known result
+// instead.
+func (b *backgroundCheck) notice() {
+	defer b.cancel()
+
+	r := b.last
+	select {
+	case <-b.done:
+		if b.result != nil {
+			r = b.result
+		}
+	case <-time.After(noticeGrace):
+		log.Printf("version check still running, telling the last known result")
+	}
+
+	if r != nil && r.Assertion != version.IsLatest && r.Assertion != version.IsUnknown {
+		warnVersion(*r)
+	}
+}
+
+// checksVersion tells if cmd checks our version on its own (and so needs no background
+// check): self-update and its subcommands
+func checksVersion(cmd *cobra.Command) bool {
+	for c := cmd; c != nil; c = c.Parent() {
+		if c == selfUpdateCmd {
+			return true
+		}
+	}
+
+	return false
+}
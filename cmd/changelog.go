@@ -0,0 +1,75 @@
+/*
+Copyright © 2022 Diego Morales <dgmorales@gmail.com>
+
+*/
+package cmd
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/dgmorales/go-cli-selfupdate/version"
+)
+
+// maxNotesLines is how many lines of each release notes we show in a changelog. Longer
+// notes are cut, pointing to the release page.
+const maxNotesLines = 8
+
+// changelog gets the notes of the releases between the current and the latest versions
+// from v, limited by --check-timeout
+func changelog(ctx context.Context, v version.Checker) ([]version.ReleaseNotes, error) {
+	cl, ok := v.(version.Changelogger)
+	if !ok {
+		return nil, errors.New("release notes are not available from this release source")
+	}
+
+	ctx, cancel := checkContext(ctx)
+	defer cancel()
+
+	return cl.Changelog(ctx)
+}
+
+// printChangelog writes notes as a condensed changelog to w, highlighting the release
+// that raised the minimal required version
+func printChangelog(w io.Writer, notes []version.ReleaseNotes) {
+	for _, n := range notes {
+		title := n.Version
+		if name := strings.TrimSpace(n.Name); name != "" && strings.TrimPrefix(name, "v") != n.Version {
+			title += " - " + name
+		}
+		if n.RaisesMinimal {
+			title = fmt.Sprintf("%s [REQUIRED: raised the minimal required version to %s]", title, n.Version)
+		}
+		fmt.Fprintf(w, "* %s\n", title)
+
+		lines := notesLines(n.Body)
+		for i, l := range lines {
+			if i == maxNotesLines && n.URL != "" {
+				fmt.Fprintf(w, "    ... (see %s)\n", n.URL)
+				break
+			}
+			if i == maxNotesLines {
+				fmt.Fprintln(w, "    ...")
+				break
+			}
+			fmt.Fprintf(w, "    %s\n", l)
+		}
+	}
+}
+
+// notesLines returns the non-blank lines of release notes body, with markdown headings
+// turned into plain text
+func notesLines(body string) []string {
+	var lines []string
+	for _, l := range strings.Split(strings.ReplaceAll(body, "\r\n", "\n"), "\n") {
+		l = strings.TrimSpace(strings.TrimLeft(strings.TrimSpace(l), "#"))
+		if l != "" {
+			lines = append(lines, l)
+		}
+	}
+
+	return lines
+}
@@ -0,0 +1,116 @@
+/*
+Copyright © 2022 Diego Morales <dgmorales@gmail.com>
+
+*/
+package cmd
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+
+	"github.com/dgmorales/go-cli-selfupdate/start"
+	"github.com/dgmorales/go-cli-selfupdate/version"
+	"sigs.k8s.io/yaml"
+)
+
+// Output formats for self-update --check
+const (
+	outputText = "text"
+	outputJSON = "json"
+	outputYAML = "yaml"
+)
+
+// checkOutput is the result of self-update --check, as written with --output json or
+// yaml. Scripts rely on it: fields may be added, but never renamed, removed or changed in
+// meaning.
+type checkOutput struct {
+	Current string `json:"current"`
+	Minimal string `json:"minimal"`
+	Latest  string `json:"latest"`
+
+	// Assertion is the version state name (like CanUpdate), and Code its exit code
+	Assertion string `json:"assertion"`
+	Code      int    `json:"code"`
+
+	// Asset is the release asset we would update with, and ReleaseURL where its release
+	// is published
+	Asset      string `json:"asset"`
+	ReleaseURL string `json:"releaseURL"`
+
+	// Violated is the version constraint clause the current version violates, if any
+	Violated string `json:"violated,omitempty"`
+
+	// HeldBack means a staged rollout holds the latest release back from us, for now
+	// (so assertion tells we are at the latest version)
+	HeldBack bool `json:"heldBack"`
+
+	// ConfigSource is where the server side config was loaded from
+	ConfigSource string `json:"configSource"`
+
+	// Error is why the latest version is unknown, if it is
+	Error string `json:"error,omitempty"`
+}
+
+// validOutput tells if format is an output format we know
+func validOutput(format string) bool {
+	return format == outputText || format == outputJSON || format == outputYAML
+}
+
+// writeCheck checks the version in state, writing the result to w in format (json or
+// yaml). It returns the check result.
+func writeCheck(w io.Writer, state start.State, format string) (version.Assertion, error) {
+	r := version.Snapshot(state.Version)
+	release := state.Version.LatestRelease()
+
+	out := checkOutput{
+		Current:      r.Current,
+		Minimal:      r.Minimal,
+		Latest:       r.Latest,
+		Assertion:    r.Assertion.String(),
+		Code:         int(r.Assertion),
+		Asset:        release.Asset,
+		ReleaseURL:   release.URL,
+		Violated:     r.Violated,
+		HeldBack:     state.Version.HeldBack(),
+		ConfigSource: state.ConfigSource(),
+		Error:        r.Error,
+	}
+
+	return r.Assertion, writeOutput(w, out, format)
+}
+
+// writeCheckError writes to w in format (json or yaml) that the version check could not
+// even start because of err (like failing to load the server side config), as a result
+// with assertion IsUnknown
+func writeCheckError(w io.Writer, err error, format string) error {
+	out := checkOutput{
+		Current:   version.Current,
+		Assertion: version.IsUnknown.String(),
+		Code:      int(version.IsUnknown),
+		Error:     err.Error(),
+	}
+
+	return writeOutput(w, out, format)
+}
+
+// writeOutput writes v to w in format (json or yaml)
+func writeOutput(w io.Writer, v interface{}, format string) error {
+	var data []byte
+	var err error
+	switch format {
+	case outputJSON:
+		data, err = json.MarshalIndent(v, "", "  ")
+		data = append(data, '\n')
+	case outputYAML:
+		data, err = yaml.Marshal(v)
+	default:
+		err = fmt.Errorf("unknown output format %q", format)
+	}
+	if err != nil {
+		return err
+	}
+
+	_, err = w.Write(data)
+	return err
+}
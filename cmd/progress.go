@@ -0,0 +1,88 @@
+/*
+Copyright © 2022 Diego Morales <dgmorales@gmail.com>
+
+*/
+package cmd
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/dgmorales/go-cli-selfupdate/version"
+	"golang.org/x/term"
+)
+
+// progressBarWidth is how many characters the bar itself takes
+const progressBarWidth = 30
+
+// progressInterval is the least time between redraws of the progress bar
+const progressInterval = 100 * time.Millisecond
+
+// progressBar draws the progress of downloads, one line per file
+type progressBar struct {
+	out      io.Writer
+	name     string
+	drawn    time.Time
+	finished bool
+}
+
+// newProgress returns a version.Progress drawing a progress bar, or nil if we are not
+// attached to a terminal (where a progress bar would only be noise)
+func newProgress() version.Progress {
+	if !term.IsTerminal(int(os.Stdout.Fd())) {
+		return nil
+	}
+
+	bar := progressBar{out: os.Stdout}
+	return bar.update
+}
+
+// update redraws the progress bar for file name, if it is time to
+func (b *progressBar) update(name string, done int64, total int64) {
+	finished := total >= 0 && done >= total
+
+	if name == b.name {
+		if b.finished || (!finished && time.Since(b.drawn) < progressInterval) {
+			return
+		}
+	} else if b.name != "" && !b.finished {
+		// the previous file was interrupted, keep its line as is
+		fmt.Fprintln(b.out)
+	}
+	b.name, b.drawn, b.finished = name, time.Now(), finished
+
+	if total <= 0 {
+		fmt.Fprintf(b.out, "\r%s %s", name, humanBytes(done))
+	} else {
+		n := int(done * progressBarWidth / total)
+		if n > progressBarWidth {
+			n = progressBarWidth
+		}
+		fmt.Fprintf(b.out, "\r%s [%s%s] %3d%% %s/%s", name,
+			strings.Repeat("=", n), strings.Repeat(" ", progressBarWidth-n),
+			done*100/total, humanBytes(done), humanBytes(total))
+	}
+
+	if finished {
+		fmt.Fprintln(b.out)
+	}
+}
+
+// humanBytes formats n bytes for humans, like 12.3 MB
+func humanBytes(n int64) string {
+	const unit = 1000
+	if n < unit {
+		return fmt.Sprintf("%d B", n)
+	}
+
+	div, exp := int64(unit), 0
+	for m := n / unit; m >= unit; m /= unit {
+		div *= unit
+		exp++
+	}
+
+	return fmt.Sprintf("%.1f %cB", float64(n)/float64(div), "kMGTPE"[exp])
+}
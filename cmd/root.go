@@ -5,14 +5,30 @@ Copyright © 2022 Diego Morales <dgmorales@gmail.com>
 package cmd
 
 import (
+	"context"
 	"os"
+	"os/signal"
+	"time"
 
 	"github.com/dgmorales/go-cli-selfupdate/kube"
+	"github.com/dgmorales/go-cli-selfupdate/selfupdate"
+	"github.com/dgmorales/go-cli-selfupdate/start"
 	"github.com/dgmorales/go-cli-selfupdate/version"
 	"github.com/spf13/cobra"
 )
 
 var flagDebug bool
+var flagChannel string
+var flagCheckTimeout time.Duration
+var flagDownloadTimeout time.Duration
+var flagCacheTTL time.Duration
+var flagBackgroundCheck bool
+var flagIgnoreRollout bool
+var flagKeepBinaries int
+
+// bgCheck is the version check running in the background, if any (see
+// --background-check)
+var bgCheck *backgroundCheck
 
 // rootCmd represents the base command when called without any subcommands
 var rootCmd = &cobra.Command{
@@ -24,12 +40,32 @@ The twist is about interacting with the kubernetes API.
 In this test, we will get version information from a ConfigMap
 stored in kubernetes.`,
 	Version: version.Current,
+	PersistentPreRun: func(cmd *cobra.Command, args []string) {
+		if flagBackgroundCheck && !checksVersion(cmd) {
+			bgCheck = startBackgroundCheck(cmd.Context())
+		}
+	},
+	PersistentPostRun: func(cmd *cobra.Command, args []string) {
+		if bgCheck != nil {
+			bgCheck.notice()
+		}
+	},
 }
 
 // Execute adds all child commands to the root command and sets flags appropriately.
 // This is called by main.main(). It only needs to happen once to the rootCmd.
+//
+// Commands run with a context canceled on the first Ctrl-C (the second one kills us, as
+// usual), so they can stop what they are waiting for and exit cleanly.
 func Execute() {
-	err := rootCmd.Execute()
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer stop()
+	go func() {
+		<-ctx.Done()
+		stop()
+	}()
+
+	err := rootCmd.ExecuteContext(ctx)
 	if err != nil {
 		os.Exit(1)
 	}
@@ -39,4 +75,40 @@ func init() {
 
 	kube.Flags.AddFlags(rootCmd.PersistentFlags())
 	rootCmd.PersistentFlags().BoolVar(&flagDebug, "debug", false, "Activates debug mode. May log very verbose output to stderr")
+	rootCmd.PersistentFlags().StringVar(&flagChannel, "channel", "", "Release channel to follow (stable, beta or nightly). Overrides the server side config")
+	rootCmd.PersistentFlags().BoolVar(&flagBackgroundCheck, "background-check", false, "Check for new versions while the command runs, telling about them at exit (or on the next run). Versions that must be updated are still refused upfront, as of the last check")
+	rootCmd.PersistentFlags().DurationVar(&flagCheckTimeout, "check-timeout", 15*time.Second, "Give up checking for new versions after this long (0 for no limit)")
+	rootCmd.PersistentFlags().DurationVar(&flagCacheTTL, "cache-ttl", time.Hour, "Reuse cached release lookups for this long before asking GitHub again (0 to always ask, with a conditional request)")
+	rootCmd.PersistentFlags().BoolVar(&flagIgnoreRollout, "ignore-rollout", false, "Offer the latest release even if its staged rollout (set in the server side config) has not reached this machine yet")
+	rootCmd.PersistentFlags().IntVar(&flagKeepBinaries, "keep-binaries", selfupdate.DefaultHistoryKeep, "How many binaries replaced by self updates to keep for rolling back to (0 to keep none)")
+	rootCmd.PersistentFlags().DurationVar(&flagDownloadTimeout, "download-timeout", 10*time.Minute, "Give up downloading a new version after this long (0 for no limit). Interrupted downloads are resumed by the next try")
+}
+
+// startOptions returns start.Options set from our global flags
+func startOptions() start.Options {
+	return start.Options{
+		Debug:         flagDebug,
+		Channel:       flagChannel,
+		CheckTimeout:  flagCheckTimeout,
+		CacheTTL:      flagCacheTTL,
+		IgnoreRollout: flagIgnoreRollout,
+	}
+}
+
+// downloadContext returns ctx limited by --download-timeout
+func downloadContext(ctx context.Context) (context.Context, context.CancelFunc) {
+	if flagDownloadTimeout <= 0 {
+		return context.WithCancel(ctx)
+	}
+
+	return context.WithTimeout(ctx, flagDownloadTimeout)
+}
+
+// checkContext returns ctx limited by --check-timeout
+func checkContext(ctx context.Context) (context.Context, context.CancelFunc) {
+	if flagCheckTimeout <= 0 {
+		return context.WithCancel(ctx)
+	}
+
+	return context.WithTimeout(ctx, flagCheckTimeout)
 }
@@ -5,18 +5,27 @@ Copyright © 2022 Diego Morales <dgmorales@gmail.com>
 package cmd
 
 import (
+	"context"
+	"errors"
 	"fmt"
+	"log"
 	"os"
+	"path/filepath"
 
 	"github.com/AlecAivazis/survey/v2"
 	"github.com/dgmorales/go-cli-selfupdate/selfupdate"
 	"github.com/dgmorales/go-cli-selfupdate/start"
 	"github.com/dgmorales/go-cli-selfupdate/version"
+	semver "github.com/hashicorp/go-version"
 	"github.com/spf13/cobra"
 )
 
 var flagCheck bool
 var flagYes bool
+var flagTo string
+var flagForce bool
+var flagChangelog bool
+var flagOutput string
 
 // selfUpdateCmd represents the selfUpdate command
 var selfUpdateCmd = &cobra.Command{
@@ -36,28 +45,124 @@ load the binary with the new version.
 You may run this command with --check for just checking if an update is available or
 required. Exit code will reflect the version state:
 
-IsLatest   = 0
-CanUpdate  = 10
-MustUpdate = 20
+IsLatest      = 0
+CanUpdate     = 10
+MustUpdate    = 20
+MustDowngrade = 40
+IsRevoked     = 50
+
+With --output json (or yaml), --check also writes the version state for scripts, as in:
+
+{
+  "current": "1.2.0",
+  "minimal": "1.0.0",
+  "latest": "1.3.0",
+  "assertion": "CanUpdate",
+  "code": 10,
+  "asset": "go-cli-selfupdate-v1.3.0-linux-amd64.tar.gz",
+  "releaseURL": "https://github.com/dgmorales/go-cli-selfupdate/releases/tag/v1.3.0",
+  "heldBack": false,
+  "configSource": "configmap/myk8sapi-system/cli-config"
+}
+
+Fields are never renamed or removed. An error field tells why the latest version is
+unknown, when it is, and a violated field which clause of the server side version
+constraint the current version violates, if any.
+
+If the check can't even start (like when the server side config can't be loaded), the
+output still follows that format, with assertion IsUnknown (code 60) and the error, and
+the exit code is 60 as well. Errors are also written to stderr.
+
+If the server side config pins a version (or caps it to a maximal allowed version),
+self-update installs that version instead of the latest release, downgrading if needed.
+
+You may also install a specific version with --to (e.g. to bisect a regression, or to
+match a colleague's version). Versions the server side config does not allow (bellow the
+minimal required, above the maximal allowed, other than the pinned one, revoked or
+outside the version constraint) are refused, unless you also pass --force.
+
+Interrupted downloads (by network errors, Ctrl-C or --download-timeout) are resumed, even
+by the next run of self-update.
+
+When a release publishes a (much smaller) patch from the current version, self-update
+downloads and applies it instead of the full release, falling back to the full release
+if the patched binary can't be verified.
+
+If this CLI trusts release signing keys (embedded at build time, or listed in the server
+side config), release binaries must be signed with one of them. Unsigned or wrongly
+signed binaries are refused.
+
+Before asking, self-update shows the notes of the releases it would update over (for
+GitHub releases). You may also just see them with --changelog. The release that raised
+the minimal required version, if any, is highlighted.
+
+If the server side config stages the rollout of new releases, only some machines are
+offered the latest release at first. Pass --ignore-rollout to get it anyway.
+
+The replaced binaries are kept (the last 3 of them, or as many as --keep-binaries), so a
+bad update can be undone without network access. See self-update history and self-update
+rollback.
 `,
 	Run: func(cmd *cobra.Command, args []string) {
-		state, err := start.ForAPIUse(flagDebug)
+		ctx := cmd.Context()
+
+		if !validOutput(flagOutput) {
+			fmt.Fprintf(os.Stderr, "Error: unknown output format %q (expected %s, %s or %s)\n", flagOutput, outputText, outputJSON, outputYAML)
+			os.Exit(1)
+		}
+		if flagOutput != outputText && !flagCheck {
+			fmt.Fprintf(os.Stderr, "Error: --output %s only works with --check\n", flagOutput)
+			os.Exit(1)
+		}
+
+		state, err := start.ForAPIUse(ctx, startOptions())
+		if err != nil && flagOutput != outputText {
+			fmt.Fprintf(os.Stderr, "Error: %s\n", err)
+			if err := writeCheckError(os.Stdout, err, flagOutput); err != nil {
+				fmt.Fprintf(os.Stderr, "Error: %s\n", err)
+				os.Exit(1)
+			}
+			// exit as the written code tells
+			os.Exit(int(version.IsUnknown))
+		}
 		if err != nil {
 			fmt.Println(err)
 			os.Exit(1)
 		}
 
-		ans := versionCheck(state.Version)
+		if flagOutput != outputText {
+			ans, err := writeCheck(os.Stdout, state, flagOutput)
+			if err != nil {
+				fmt.Fprintf(os.Stderr, "Error: %s\n", err)
+				os.Exit(1)
+			}
+			os.Exit(int(ans))
+		}
+
+		if flagTo != "" {
+			installVersion(ctx, state.Version, flagTo)
+		}
+
+		if flagChangelog {
+			showChangelog(ctx, state.Version)
+		}
+
+		ans := versionCheck(ctx, state.Version)
 
 		// versionCheck is meant to run from any Command
 		// The bellow switch looks again to the version check assertion and performs
 		// user interactions we want only on this command.
 		switch ans {
 		case version.IsLatest:
+			if state.Version.HeldBack() {
+				fmt.Printf("You are up to date. Release %s is being rolled out to other machines first (pass --ignore-rollout to get it now)\n",
+					state.Version.Latest())
+				break
+			}
 			fmt.Printf("You are at the latest version (%s)\n", state.Version.Latest())
 		case version.CanUpdate:
 			if !flagCheck {
-				confirmAndUpdate(ans, state.Version)
+				confirmAndUpdate(ctx, ans, state.Version)
 			}
 		}
 
@@ -69,37 +174,112 @@ func init() {
 	rootCmd.AddCommand(selfUpdateCmd)
 
 	selfUpdateCmd.Flags().BoolVarP(&flagCheck, "check", "c", false, "Just check and report if there is a new version available.")
-	selfUpdateCmd.Flags().BoolVarP(&flagYes, "yes", "y", false, "Don't ask before changing your system. Assume yes.")
+	selfUpdateCmd.PersistentFlags().BoolVarP(&flagYes, "yes", "y", false, "Don't ask before changing your system. Assume yes.")
+	selfUpdateCmd.Flags().StringVar(&flagTo, "to", "", "Install this version instead of the latest one (e.g. v1.2.3).")
+	selfUpdateCmd.Flags().BoolVar(&flagForce, "force", false, "With --to, install the version even if the server side config does not allow it.")
+	selfUpdateCmd.Flags().StringVarP(&flagOutput, "output", "o", outputText, "With --check, how to report the version state: text, json or yaml.")
+	selfUpdateCmd.Flags().BoolVar(&flagChangelog, "changelog", false, "Just show the release notes between the current and the latest version.")
+	selfUpdateCmd.MarkFlagsMutuallyExclusive("check", "to", "changelog")
 }
 
 // versionCheck checks if current version can or must be updated, and interacts with the user
 // about it
-func versionCheck(v version.Checker) version.Assertion {
-	ans := v.Check()
+func versionCheck(ctx context.Context, v version.Checker) version.Assertion {
+	r := version.Snapshot(v)
+	ans := r.Assertion
+	warnVersion(r)
 
 	switch ans {
+	case version.MustUpdate, version.MustDowngrade:
+		if !flagCheck {
+			confirmAndUpdate(ctx, ans, v)
+		}
+
+	case version.IsRevoked:
+		if !flagCheck {
+			if v.Latest() == "" || v.Latest() == v.Current() {
+				fmt.Println("There is no release to update to yet. Cannot continue. Exiting.")
+				os.Exit(int(ans))
+			}
+			confirmAndUpdate(ctx, ans, v)
+		}
+
+	case version.CanUpdate:
+		// UX decision: just warns, and do not ask the user for update if it is not required.
+	}
+
+	return ans
+}
+
+// warnVersion tells the user about the version check result r, if there is anything to
+// tell
+func warnVersion(r version.Result) {
+	switch r.Assertion {
 	case version.MustUpdate:
+		if r.Violated != "" {
+			fmt.Printf("Warning: your current version (%s) is not supported anymore: it violates the version constraint %q (latest: %s). You need to update it.\n",
+				r.Current, r.Violated, r.Latest)
+			return
+		}
 		fmt.Printf("Warning: your current version (%s) is not supported anymore (minimal: %s, latest: %s). You need to update it.\n",
-			v.Current(), v.Minimal(), v.Latest())
+			r.Current, r.Minimal, r.Latest)
 
-		if !flagCheck {
-			confirmAndUpdate(ans, v)
+	case version.IsRevoked:
+		if r.Violated != "" {
+			fmt.Printf("Warning: your current version (%s) was revoked and must not be used: it violates the version constraint %q (latest: %s). You need to update it.\n",
+				r.Current, r.Violated, r.Latest)
+			return
+		}
+		fmt.Printf("Warning: your current version (%s) was revoked and must not be used (latest: %s). You need to update it.\n",
+			r.Current, r.Latest)
+
+	case version.MustDowngrade:
+		if r.Violated != "" {
+			fmt.Printf("Warning: your current version (%s) is above the allowed versions: it violates the version constraint %q. You need to change it to %s.\n",
+				r.Current, r.Violated, r.Latest)
+			return
 		}
+		fmt.Printf("Warning: your current version (%s) is above the maximal allowed (or pinned) version. You need to change it to %s.\n",
+			r.Current, r.Latest)
 
 	case version.CanUpdate:
 		fmt.Printf("Warning: there's a newer version (%s), but this version (%s) is still usable. You can update it by running %s self-update.\n",
-			v.Latest(), v.Current(), os.Args[0])
-		// UX decision: just warns, and do not ask the user for update if it is not required.
+			r.Latest, r.Current, os.Args[0])
 
 	case version.IsBeyond:
 		fmt.Printf("Warning: your are using a development version (current %s > latest release %s).\n",
-			v.Current(), v.Latest())
+			r.Current, r.Latest)
 
 	case version.IsUnknown:
+		if r.Error != "" {
+			fmt.Printf("Warning: couldn't check if you are running the latest (or minimal) version: %s.\n", r.Error)
+			return
+		}
 		fmt.Println("Warning: couldn't check if you are running the latest (or minimal) version.")
 	}
+}
 
-	return ans
+// showChangelog shows the notes of the releases between the current and the latest
+// versions. This function always terminates the program.
+func showChangelog(ctx context.Context, v version.Checker) {
+	if v.Latest() == "" {
+		fmt.Println("Error: couldn't find out the latest version")
+		os.Exit(1)
+	}
+
+	notes, err := changelog(ctx, v)
+	if err != nil {
+		fmt.Printf("Error: %s\n", err)
+		os.Exit(1)
+	}
+
+	if len(notes) == 0 {
+		fmt.Printf("No releases above your version (%s) up to the latest one (%s)\n", v.Current(), v.Latest())
+		os.Exit(0)
+	}
+
+	printChangelog(os.Stdout, notes)
+	os.Exit(0)
 }
 
 // confirmAndUpdate will confirms if we can proceed with the self-update,
@@ -109,9 +289,20 @@ func versionCheck(v version.Checker) version.Assertion {
 //
 // If the update is **not required** and not performed this function returns.
 // Otherwise this function ensures the program is terminated.
-func confirmAndUpdate(a version.Assertion, v version.Checker) {
-	if !flagYes && !askIfUpdate() {
-		if a == version.MustUpdate {
+func confirmAndUpdate(ctx context.Context, a version.Assertion, v version.Checker) {
+	if !flagYes {
+		notes, err := changelog(ctx, v)
+		if err != nil {
+			log.Printf("not showing the changelog: %s", err)
+		}
+		if len(notes) > 0 {
+			fmt.Printf("Changes since your version (%s):\n", v.Current())
+			printChangelog(os.Stdout, notes)
+		}
+	}
+
+	if !flagYes && !askIfUpdate(v.Latest()) {
+		if a == version.MustUpdate || a == version.MustDowngrade || a == version.IsRevoked {
 			fmt.Println("Cannot continue without updating. Exiting.")
 			os.Exit(int(a))
 		}
@@ -119,27 +310,111 @@ func confirmAndUpdate(a version.Assertion, v version.Checker) {
 		return
 	}
 
-	fmt.Println("Downloading and applying latest release ...")
-	filename, err := v.DownloadLatest()
+	fmt.Printf("Downloading and applying release %s ...\n", v.Latest())
+	downloadAndApply(ctx, v, v.Latest(), v.DownloadLatest)
+}
+
+// installVersion installs version to instead of the latest one, asking the user first
+// (unless --yes).
+//
+// It refuses versions the server side version policy does not allow (like revoked ones,
+// or bellow the minimal required), unless --force. This function always terminates the
+// program.
+func installVersion(ctx context.Context, v version.Checker, to string) {
+	want, err := semver.NewSemver(to)
+	if err != nil {
+		fmt.Printf("Error: invalid version %q: %s\n", to, err)
+		os.Exit(1)
+	}
+
+	if why := v.Refused(to); why != "" && !flagForce {
+		fmt.Printf("Error: version %s is not allowed: %s. Pass --force to install it anyway.\n", want, why)
+		os.Exit(1)
+	}
+
+	if cur, err := semver.NewSemver(v.Current()); err == nil && want.Equal(cur) {
+		fmt.Printf("You are already at version %s\n", want)
+		os.Exit(0)
+	}
+
+	if !flagYes && !askIfUpdate(want.String()) {
+		os.Exit(0)
+	}
+
+	fmt.Printf("Downloading and applying release %s ...\n", want)
+	downloadAndApply(ctx, v, to, func(ctx context.Context) (*version.Asset, error) {
+		return v.DownloadVersion(ctx, to)
+	})
+}
+
+// downloadAndApply downloads release version to and applies it, overwriting the current
+// binary.
+//
+// It prefers a patch from the current version, if the release has one, falling back to
+// the full release (got with download) if there is none or if it fails to apply.
+//
+// Downloads are limited by --download-timeout, and canceled by ctx.
+//
+// This function always terminates the program, to force the user to load the updated
+// binary.
+func downloadAndApply(ctx context.Context, v version.Checker, to string, download func(context.Context) (*version.Asset, error)) {
+	v.SetProgress(newProgress())
+
+	ctx, cancel := downloadContext(ctx)
+	defer cancel()
+
+	asset, err := v.DownloadPatch(ctx, to)
+	if err == nil {
+		err = apply(asset)
+		if err == nil {
+			os.Exit(0)
+		}
+	}
+	if ctx.Err() != nil {
+		fmt.Printf("Error: %s\n", err)
+		os.Exit(1)
+	}
+	if !errors.Is(err, version.ErrNoPatch) {
+		fmt.Printf("Warning: couldn't update with a patch (%s). Downloading the full release instead.\n", err)
+	}
+
+	asset, err = download(ctx)
 	if err != nil {
 		fmt.Printf("Error: %s", err.Error())
 		os.Exit(1)
 	}
-	err = selfupdate.Apply(filename)
+	err = apply(asset)
 	if err != nil {
 		fmt.Printf("Error: %s", err.Error())
 		os.Exit(1)
 	}
 
-	// Terminate to force the user to load the updated binary
 	os.Exit(0)
 }
 
-// askIfUpdate will ask the user if we should update now
-func askIfUpdate() bool {
+// apply applies the downloaded release asset, overwriting the current binary
+//
+// It warns (on stderr) when the release published no checksums to verify the asset with.
+func apply(asset *version.Asset) error {
+	if asset.SHA256 == nil && asset.BinarySHA256 == nil {
+		fmt.Fprintf(os.Stderr, "Warning: the release publishes no checksums, so %s could not be verified.\n",
+			filepath.Base(asset.Filename))
+	}
+
+	selfupdate.HistoryKeep = flagKeepBinaries
+	return selfupdate.Apply(asset.Filename, version.Current, selfupdate.Options{
+		SHA256:       asset.SHA256,
+		BinarySHA256: asset.BinarySHA256,
+		Signature:    asset.Signature,
+		Patch:        asset.Patch,
+	})
+}
+
+// askIfUpdate will ask the user if we should update to version v now
+func askIfUpdate(v string) bool {
 	ans := false
 	prompt := &survey.Confirm{
-		Message: "Can I download the latest version and self-update now?",
+		Message: fmt.Sprintf("Can I download version %s and self-update now?", v),
 		Default: true,
 	}
 	survey.AskOne(prompt, &ans)
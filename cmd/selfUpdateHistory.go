@@ -0,0 +1,52 @@
+/*
+Copyright © 2022 Diego Morales <dgmorales@gmail.com>
+
+*/
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"text/tabwriter"
+	"time"
+
+	"github.com/dgmorales/go-cli-selfupdate/selfupdate"
+	"github.com/dgmorales/go-cli-selfupdate/start"
+	"github.com/spf13/cobra"
+)
+
+// selfUpdateHistoryCmd represents the self-update history command
+var selfUpdateHistoryCmd = &cobra.Command{
+	Use:   "history",
+	Short: "List the binaries replaced by self updates",
+	Long: `history lists the binaries replaced by self updates, most recent first.
+
+These are the versions self-update rollback can go back to, in order.
+`,
+	Run: func(cmd *cobra.Command, args []string) {
+		// no need to talk to our servers to look at local files
+		start.ForLocalUse(flagDebug)
+
+		h, err := selfupdate.History()
+		if err != nil {
+			fmt.Println(err)
+			os.Exit(1)
+		}
+
+		if len(h) == 0 {
+			fmt.Printf("No previous binaries kept in %s\n", selfupdate.HistoryDir)
+			return
+		}
+
+		w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+		fmt.Fprintln(w, "VERSION\tREPLACED AT\tPATH")
+		for _, b := range h {
+			fmt.Fprintf(w, "%s\t%s\t%s\n", b.Version, b.ReplacedAt.Format(time.RFC3339), b.Path())
+		}
+		w.Flush()
+	},
+}
+
+func init() {
+	selfUpdateCmd.AddCommand(selfUpdateHistoryCmd)
+}
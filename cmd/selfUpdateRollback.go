@@ -0,0 +1,73 @@
+/*
+Copyright © 2022 Diego Morales <dgmorales@gmail.com>
+
+*/
+package cmd
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/AlecAivazis/survey/v2"
+	"github.com/dgmorales/go-cli-selfupdate/selfupdate"
+	"github.com/dgmorales/go-cli-selfupdate/start"
+	"github.com/dgmorales/go-cli-selfupdate/version"
+	"github.com/spf13/cobra"
+)
+
+// selfUpdateRollbackCmd represents the self-update rollback command
+var selfUpdateRollbackCmd = &cobra.Command{
+	Use:   "rollback",
+	Short: "Roll back to the binary replaced by the last self update",
+	Long: `rollback undoes the last self update, restoring the binary it replaced.
+
+It needs no network access. Calling it again goes further back, as long as there are
+binaries kept (see self-update history). It always asks first, though (unless you pass
+--yes).
+
+Note that the server side config may still require a newer version than the one you
+roll back to.
+`,
+	Run: func(cmd *cobra.Command, args []string) {
+		// rolling back must work even when our servers are unreachable
+		start.ForLocalUse(flagDebug)
+
+		h, err := selfupdate.History()
+		if err != nil {
+			fmt.Println(err)
+			os.Exit(1)
+		}
+		if len(h) == 0 {
+			fmt.Println(selfupdate.ErrNoHistory)
+			os.Exit(1)
+		}
+
+		if !flagYes && !askIfRollback(h[0].Version) {
+			return
+		}
+
+		b, err := selfupdate.Rollback()
+		if err != nil {
+			fmt.Printf("Error: %s\n", err)
+			os.Exit(1)
+		}
+
+		fmt.Printf("Rolled back from %s to %s\n", version.Current, b.Version)
+	},
+}
+
+func init() {
+	selfUpdateCmd.AddCommand(selfUpdateRollbackCmd)
+}
+
+// askIfRollback will ask the user if we should roll back to version v now
+func askIfRollback(v string) bool {
+	ans := false
+	prompt := &survey.Confirm{
+		Message: fmt.Sprintf("Can I roll back to version %s now?", v),
+		Default: true,
+	}
+	survey.AskOne(prompt, &ans)
+
+	return ans
+}
@@ -0,0 +1,134 @@
+/*
+Copyright © 2022 Diego Morales <dgmorales@gmail.com>
+
+*/
+package cmd
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"text/tabwriter"
+
+	"github.com/dgmorales/go-cli-selfupdate/start"
+	"github.com/dgmorales/go-cli-selfupdate/version"
+	"github.com/spf13/cobra"
+)
+
+var flagRemote bool
+var flagVersionOutput string
+
+// versionOutput is what the version command reports. Like checkOutput, scripts may rely
+// on it: fields may be added, but never renamed or removed.
+type versionOutput struct {
+	version.BuildInfo
+
+	// Remote is what our servers say about versions, with --remote
+	Remote *remoteVersions `json:"remote,omitempty"`
+}
+
+// remoteVersions are the versions our servers tell about (see version --remote)
+type remoteVersions struct {
+	Minimal      string `json:"minimal"`
+	Latest       string `json:"latest"`
+	Assertion    string `json:"assertion"`
+	ConfigSource string `json:"configSource"`
+	Error        string `json:"error,omitempty"`
+}
+
+// versionCmd represents the version command
+var versionCmd = &cobra.Command{
+	Use:   "version",
+	Short: "Show this CLI version and where it was built from",
+	Long: `version shows this CLI version along with its build provenance: the commit it was
+built from (and if there were uncommitted changes), when, with which Go version, and the
+versions of the libraries it talks to our servers and release sources with.
+
+With --remote, it also shows the minimal required and latest versions, as told by our
+servers (just like self-update --check, but without acting on them).
+
+With --output json, the same is written as JSON, for scripts.
+`,
+	Run: func(cmd *cobra.Command, args []string) {
+		if flagVersionOutput != outputText && flagVersionOutput != outputJSON {
+			fmt.Printf("Error: unknown output format %q (expected %s or %s)\n", flagVersionOutput, outputText, outputJSON)
+			os.Exit(1)
+		}
+
+		out := versionOutput{BuildInfo: version.ReadBuildInfo()}
+
+		if flagRemote {
+			out.Remote = &remoteVersions{}
+			state, err := start.ForAPIUse(cmd.Context(), startOptions())
+			if err != nil {
+				out.Remote.Error = err.Error()
+			} else {
+				r := version.Snapshot(state.Version)
+				out.Remote.Minimal, out.Remote.Latest = r.Minimal, r.Latest
+				out.Remote.Assertion = r.Assertion.String()
+				out.Remote.ConfigSource = state.ConfigSource()
+				out.Remote.Error = r.Error
+			}
+		} else {
+			// no need to talk to our servers for what we know about ourselves
+			start.ForLocalUse(flagDebug)
+		}
+
+		if flagVersionOutput == outputJSON {
+			err := writeOutput(os.Stdout, out, outputJSON)
+			if err != nil {
+				fmt.Fprintf(os.Stderr, "Error: %s\n", err)
+				os.Exit(1)
+			}
+			return
+		}
+
+		printVersion(os.Stdout, out)
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(versionCmd)
+
+	versionCmd.Flags().BoolVar(&flagRemote, "remote", false, "Also show the minimal required and latest versions, as told by our servers.")
+	versionCmd.Flags().StringVarP(&flagVersionOutput, "output", "o", outputText, "How to show it: text or json.")
+}
+
+// printVersion writes out as text to w
+func printVersion(w io.Writer, out versionOutput) {
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	defer tw.Flush()
+
+	fmt.Fprintf(tw, "Version:\t%s\n", out.Version)
+
+	commit := out.Commit
+	if commit == "" {
+		commit = "unknown"
+	}
+	if out.Dirty {
+		commit += " (dirty)"
+	}
+	fmt.Fprintf(tw, "Commit:\t%s\n", commit)
+	if out.CommitTime != "" {
+		fmt.Fprintf(tw, "Commit time:\t%s\n", out.CommitTime)
+	}
+	if out.BuildTime != "" {
+		fmt.Fprintf(tw, "Build time:\t%s\n", out.BuildTime)
+	}
+	fmt.Fprintf(tw, "Go version:\t%s\n", out.GoVersion)
+	fmt.Fprintf(tw, "Platform:\t%s\n", out.Platform)
+	for _, dep := range out.Deps {
+		fmt.Fprintf(tw, "%s:\t%s\n", dep.Path, dep.Version)
+	}
+
+	if r := out.Remote; r != nil {
+		if r.Error != "" && r.Latest == "" {
+			fmt.Fprintf(tw, "Remote:\terror: %s\n", r.Error)
+			return
+		}
+		fmt.Fprintf(tw, "Minimal required:\t%s\n", r.Minimal)
+		fmt.Fprintf(tw, "Latest:\t%s\n", r.Latest)
+		fmt.Fprintf(tw, "Version state:\t%s\n", r.Assertion)
+		fmt.Fprintf(tw, "Config source:\t%s\n", r.ConfigSource)
+	}
+}
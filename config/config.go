@@ -1,5 +1,17 @@
 package config
 
+import "context"
+
+// Release sources we know how to check for new versions
+const (
+	SourceGitHub = "github"
+	SourceGitLab = "gitlab"
+	SourceHTTP   = "http"
+	SourceLocal  = "local"
+	SourceOCI    = "oci"
+	SourceGitea  = "gitea"
+)
+
 // ServerSideConfig represents configuration we store on the server side of our CLI application
 //
 // These are things that do not make sense to store in a local config, like the minimal
@@ -9,9 +21,96 @@ type ServerSideConfig struct {
 	RepoOwner              string
 	RepoName               string
 	MinimalRequiredVersion string
+
+	// MaximalAllowedVersion is the highest version users may run (e.g. while a newer
+	// release is known to be broken against our servers). Users above it must downgrade,
+	// and newer releases are not offered.
+	MaximalAllowedVersion string
+
+	// PinnedVersion is the exact version users must run. When set, it takes precedence
+	// over MaximalAllowedVersion and the latest release: users below it must update,
+	// and users above it must downgrade.
+	PinnedVersion string
+
+	// RevokedVersions are versions users must not run, even if above the minimal
+	// required, like releases with a security bug. Users running them must update. Put
+	// one entry per line (or separate them with ;), each either an exact version (2.3.1)
+	// or a range (>= 2.3.0, < 2.3.2).
+	//
+	// Revoked versions are never offered: if the latest release is revoked, users are
+	// offered the newest release below it instead.
+	RevokedVersions string
+
+	// ReleaseSource is where releases are published: github (the default, if blank),
+	// gitlab, gitea (also for Forgejo), http (a release manifest on a plain web server),
+	// local (a directory, like a shared mount, for air-gapped environments) or oci
+	// (artifacts in a container registry).
+	//
+	// For gitlab, RepoOwner is the group (or subgroup path) and RepoName the project. You
+	// may also leave RepoOwner blank and set RepoName to the numeric project ID.
+	//
+	// For oci, RepoOwner and RepoName make up the repository path in the registry.
+	ReleaseSource string
+
+	// ReleaseURL is the base address of the release source, when it is not implied by
+	// ReleaseSource (e.g. https://gitlab.example.com or https://registry.example.com).
+	// For http, it is the address of the release manifest itself. For local, it is the
+	// directory path or file:// URL.
+	ReleaseURL string
+
+	// AssetPattern is how release assets are named, for picking the one for the user
+	// platform. It is a regular expression where {name}, {version}, {os}, {arch} and
+	// {ext} stand for any name, the release version, the OS, the architecture and the
+	// archive extension. Blank means {name}-{version}-{os}-{arch}\.{ext}, which is what
+	// our release workflow produces. Not used for release sources that list assets by
+	// platform (http, local with an index, and oci with an image index).
+	AssetPattern string
+
+	// TrustedKeys are minisign public keys (as in the second line of a .pub file) release
+	// binaries must be signed with, besides the ones embedded in the CLI at build time.
+	// Put one per line (or separate them with ;). With any trusted key, unsigned binaries
+	// are refused.
+	//
+	// The signature of a binary is published as a release asset named after the archive
+	// with the binary, without the extension, plus .minisig (like
+	// go-cli-selfupdate-v0.4.0-linux-amd64.minisig).
+	TrustedKeys string
+
+	// Channel is the release channel users follow, unless they choose another one
+	// locally: stable (the default, if blank), beta or nightly. Only supported for
+	// github, for now.
+	Channel string
+
+	// RolloutPercent stages the rollout of new releases: only this percentage (0 to 100,
+	// like 10 or 2.5) of machines are offered the latest release, the others being told
+	// they are at the latest version. Machines are bucketed by an ID kept locally, so
+	// each one stays in the rollout as it grows. Blank means no staged rollout: every
+	// machine is offered new releases right away. Required updates (like below
+	// MinimalRequiredVersion) are never held back.
+	RolloutPercent string
+
+	// RolloutStart is when the rollout starts (RFC 3339, like 2022-12-01T10:00:00Z). No
+	// machine is offered the latest release before it. Blank means it already started.
+	RolloutStart string
+
+	// RolloutRamp is how long the rollout takes to grow from 0 to RolloutPercent, from
+	// RolloutStart (like 72h). Blank means there is no ramp. Requires RolloutStart.
+	RolloutRamp string
+
+	// VersionConstraint is a version constraint users must run a version within, for
+	// when MinimalRequiredVersion is not enough, like ">= 0.4.0, != 0.5.1, < 2.0.0".
+	// Versions below a clause must update, versions above it must downgrade, and
+	// versions excluded with != are revoked. It applies along with the other version
+	// fields. Releases outside it are never offered.
+	VersionConstraint string
 }
 
 // ServerSideConfigLoader knows how to reach, read and parse our server side config.
+//
+// Load gives up when ctx is done.
 type ServerSideConfigLoader interface {
-	Load() (ServerSideConfig, error)
+	Load(ctx context.Context) (ServerSideConfig, error)
+
+	// Source tells where the config is loaded from, for humans (and scripts) to see
+	Source() string
 }
@@ -55,11 +55,17 @@ func NewKubeSSCfgLoader(kc *kubernetes.Clientset, ns string, cmName string) (Kub
 	}, nil
 }
 
+// Source tells which ConfigMap the config is loaded from, like
+// configmap/myk8sapi-system/cli-config
+func (k KubeServerSideConfigLoader) Source() string {
+	return fmt.Sprintf("configmap/%s/%s", k.ns, k.cmName)
+}
+
 // Load loads server side configuration from a Kubernetes ConfigMap
-func (k KubeServerSideConfigLoader) Load() (ServerSideConfig, error) {
+func (k KubeServerSideConfigLoader) Load(ctx context.Context) (ServerSideConfig, error) {
 	cfg := ServerSideConfig{}
 
-	cm, err := k.client.CoreV1().ConfigMaps(k.ns).Get(context.Background(), k.cmName,
+	cm, err := k.client.CoreV1().ConfigMaps(k.ns).Get(ctx, k.cmName,
 		metav1.GetOptions{})
 	if err != nil {
 		return cfg, fmt.Errorf("error reading configmap %s/%s: %w", k.ns, k.cmName, err)
@@ -2,15 +2,27 @@ package gh
 
 import (
 	"context"
+	"net/http"
 	"os"
 
 	"github.com/google/go-github/v48/github"
 	"golang.org/x/oauth2"
 )
 
-func NewClient() (*github.Client, error) {
+// NewClient returns a GitHub client, authenticated with a token from GITHUB_TOKEN if set.
+// Otherwise, requests are anonymous.
+//
+// base makes the actual requests, under authentication (like an httpcache.Transport). If
+// nil, http.DefaultTransport is used.
+func NewClient(base http.RoundTripper) (*github.Client, error) {
+	httpClient := &http.Client{Transport: base}
+
 	token := os.Getenv("GITHUB_TOKEN")
-	ctx := context.Background()
+	if token == "" {
+		return github.NewClient(httpClient), nil
+	}
+
+	ctx := context.WithValue(context.Background(), oauth2.HTTPClient, httpClient)
 	ts := oauth2.StaticTokenSource(
 		&oauth2.Token{AccessToken: token},
 	)
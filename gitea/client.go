@@ -0,0 +1,33 @@
+package gitea
+
+import (
+	"fmt"
+	"net/http"
+	"net/url"
+	"os"
+
+	"github.com/dgmorales/go-cli-selfupdate/hostauth"
+)
+
+// NewClient returns an http.Client for talking to the Gitea (or Forgejo) instance at
+// baseURL.
+//
+// It authenticates with an access token from GITEA_TOKEN (or FORGEJO_TOKEN) if set.
+// Otherwise, requests are anonymous.
+func NewClient(baseURL string) (*http.Client, error) {
+	u, err := url.Parse(baseURL)
+	if err != nil {
+		return nil, fmt.Errorf("error parsing gitea url %s: %w", baseURL, err)
+	}
+
+	token := os.Getenv("GITEA_TOKEN")
+	if token == "" {
+		token = os.Getenv("FORGEJO_TOKEN")
+	}
+	if token == "" {
+		return &http.Client{}, nil
+	}
+
+	// the token only goes to the Gitea instance, never to other hosts
+	return &http.Client{Transport: hostauth.NewTransport(u, "Authorization", "token "+token, nil)}, nil
+}
@@ -0,0 +1,35 @@
+package gl
+
+import (
+	"fmt"
+	"net/http"
+	"net/url"
+	"os"
+
+	"github.com/dgmorales/go-cli-selfupdate/hostauth"
+)
+
+// NewClient returns an http.Client for talking to the GitLab instance at baseURL.
+//
+// It authenticates with a personal/project access token from GITLAB_TOKEN if set, or
+// with a CI job token from CI_JOB_TOKEN (set when running inside GitLab CI). If none
+// of them are set, requests are anonymous.
+func NewClient(baseURL string) (*http.Client, error) {
+	u, err := url.Parse(baseURL)
+	if err != nil {
+		return nil, fmt.Errorf("error parsing gitlab url %s: %w", baseURL, err)
+	}
+
+	var header, token string
+	if token = os.Getenv("GITLAB_TOKEN"); token != "" {
+		header = "PRIVATE-TOKEN"
+	} else if token = os.Getenv("CI_JOB_TOKEN"); token != "" {
+		header = "JOB-TOKEN"
+	} else {
+		return &http.Client{}, nil
+	}
+
+	// the token only goes to the GitLab instance, never to other hosts (like asset links
+	// pointing elsewhere)
+	return &http.Client{Transport: hostauth.NewTransport(u, header, token, nil)}, nil
+}
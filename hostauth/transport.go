@@ -0,0 +1,40 @@
+package hostauth
+
+import (
+	"net/http"
+	"net/url"
+)
+
+// Transport adds an auth header to requests going to the scheme and host of a base URL
+// only, so the credential never leaks to other hosts (like asset links pointing
+// elsewhere), nor goes in cleartext (like an http:// redirect on the same host).
+type Transport struct {
+	scheme string
+	host   string
+	header string
+	value  string
+	base   http.RoundTripper
+}
+
+// NewTransport returns a Transport setting header to value on requests going to the
+// scheme and host of baseURL.
+//
+// base makes the actual requests. If nil, http.DefaultTransport is used.
+func NewTransport(baseURL *url.URL, header string, value string, base http.RoundTripper) *Transport {
+	if base == nil {
+		base = http.DefaultTransport
+	}
+
+	return &Transport{scheme: baseURL.Scheme, host: baseURL.Host, header: header, value: value, base: base}
+}
+
+func (t *Transport) RoundTrip(req *http.Request) (*http.Response, error) {
+	if req.URL.Scheme != t.scheme || req.URL.Host != t.host {
+		return t.base.RoundTrip(req)
+	}
+
+	// RoundTrippers should not modify the original request
+	r := req.Clone(req.Context())
+	r.Header.Set(t.header, t.value)
+	return t.base.RoundTrip(r)
+}
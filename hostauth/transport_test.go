@@ -0,0 +1,66 @@
+package hostauth_test
+
+import (
+	"net/http"
+	"net/url"
+	"testing"
+
+	"github.com/dgmorales/go-cli-selfupdate/hostauth"
+)
+
+// headerRecorder is a RoundTripper that keeps the auth header of the last request, not
+// making it
+type headerRecorder struct {
+	got string
+}
+
+func (r *headerRecorder) RoundTrip(req *http.Request) (*http.Response, error) {
+	r.got = req.Header.Get("Authorization")
+	return &http.Response{StatusCode: http.StatusOK, Body: http.NoBody, Request: req}, nil
+}
+
+func TestTransportOnlyAuthenticatesBaseSchemeAndHost(t *testing.T) {
+	testCases := []struct {
+		desc   string
+		url    string
+		authed bool
+	}{
+		{
+			desc:   "AuthenticatesSameSchemeAndHost",
+			url:    "https://forge.example.com/api/v1/repos",
+			authed: true,
+		},
+		{
+			desc: "SkipsOtherHost",
+			url:  "https://cdn.example.com/asset.tar.gz",
+		},
+		{
+			desc: "SkipsOtherPort",
+			url:  "https://forge.example.com:8443/asset.tar.gz",
+		},
+		{
+			desc: "SkipsCleartextOnSameHost",
+			url:  "http://forge.example.com/asset.tar.gz",
+		},
+	}
+	for _, tC := range testCases {
+		t.Run(tC.desc, func(t *testing.T) {
+			base, _ := url.Parse("https://forge.example.com")
+			rec := &headerRecorder{}
+			client := &http.Client{Transport: hostauth.NewTransport(base, "Authorization", "token secret", rec)}
+
+			resp, err := client.Get(tC.url)
+			if err != nil {
+				t.Fatalf("expected nil error, got %s", err)
+			}
+			resp.Body.Close()
+
+			if tC.authed && rec.got != "token secret" {
+				t.Errorf("expected request to %s to be authenticated, it was not", tC.url)
+			}
+			if !tC.authed && rec.got != "" {
+				t.Errorf("expected request to %s not to be authenticated, got header %q", tC.url, rec.got)
+			}
+		})
+	}
+}
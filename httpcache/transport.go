@@ -0,0 +1,260 @@
+package httpcache
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"mime"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/dgmorales/go-cli-selfupdate/logger"
+)
+
+// maxEntrySize limits the size of cached responses. Release lookups are much smaller.
+const maxEntrySize = 1 << 20
+
+// storedHeaders are the response headers we keep in the cache. Others (like rate limit
+// headers) would be stale when the response is served from the cache.
+var storedHeaders = []string{"Content-Type", "Etag", "Last-Modified", "Link"}
+
+// Transport is an http.RoundTripper caching JSON responses to GET requests (like release
+// lookups) on disk, so they can be shared by CLI runs.
+//
+// Cached responses are served without asking the server for TTL. After that, they are
+// revalidated with a conditional request (If-None-Match, with their ETag), which the
+// server answers with 304 Not Modified (and no body) if they are still current. GitHub
+// does not count those against rate limits.
+//
+// Entries are written to temporary files and renamed into place, so concurrent CLI runs
+// may share Dir safely: they always read whole entries, the last one written winning.
+type Transport struct {
+	// Dir is where responses are cached. It is created if missing.
+	Dir string
+
+	// TTL is for how long cached responses are served without revalidating them
+	TTL time.Duration
+
+	// Base makes the actual requests. If nil, http.DefaultTransport is used.
+	Base http.RoundTripper
+}
+
+// entry is a cached response
+type entry struct {
+	URL      string      `json:"url"`
+	StoredAt time.Time   `json:"storedAt"`
+	Header   http.Header `json:"header"`
+	Body     []byte      `json:"body"`
+}
+
+// DefaultDir returns the XDG cache dir for our HTTP cache, falling back to
+// logger.WorkDir if we can't tell where the user cache is
+func DefaultDir() string {
+	cacheDir, err := os.UserCacheDir()
+	if err != nil {
+		return filepath.Join(logger.WorkDir, "cache")
+	}
+
+	return filepath.Join(cacheDir, "go-cli-selfupdate", "http")
+}
+
+func (t *Transport) RoundTrip(req *http.Request) (*http.Response, error) {
+	if req.Method != http.MethodGet || req.Header.Get("Range") != "" {
+		return t.base().RoundTrip(req)
+	}
+
+	path := t.entryPath(req)
+	e, err := t.load(path)
+	if err != nil {
+		log.Printf("ignoring http cache entry %s: %s", path, err)
+	}
+
+	if e != nil && time.Since(e.StoredAt) < t.TTL {
+		log.Printf("using cached response for %s (from %s)", req.URL, e.StoredAt.Format(time.RFC3339))
+		return e.response(req, nil), nil
+	}
+
+	if e != nil && e.Header.Get("Etag") != "" {
+		// RoundTrippers should not modify the original request
+		r := req.Clone(req.Context())
+		r.Header.Set("If-None-Match", e.Header.Get("Etag"))
+		req = r
+	}
+
+	resp, err := t.base().RoundTrip(req)
+	if err != nil {
+		return nil, err
+	}
+
+	if e != nil && resp.StatusCode == http.StatusNotModified {
+		resp.Body.Close()
+		log.Printf("cached response for %s is still current", req.URL)
+
+		e.StoredAt = time.Now()
+		t.store(path, e)
+		return e.response(req, resp.Header), nil
+	}
+
+	if !cacheable(resp) {
+		return resp, nil
+	}
+
+	// read the body to cache it, giving it back whole (from what we read and what is
+	// left) if it is too big to cache
+	body, err := io.ReadAll(io.LimitReader(resp.Body, maxEntrySize+1))
+	if err != nil {
+		resp.Body.Close()
+		return nil, err
+	}
+	if len(body) > maxEntrySize {
+		resp.Body = readCloser{io.MultiReader(bytes.NewReader(body), resp.Body), resp.Body}
+		return resp, nil
+	}
+	resp.Body.Close()
+	resp.Body = io.NopCloser(bytes.NewReader(body))
+
+	e = &entry{URL: req.URL.String(), StoredAt: time.Now(), Header: http.Header{}, Body: body}
+	for _, h := range storedHeaders {
+		if v, ok := resp.Header[h]; ok {
+			e.Header[h] = v
+		}
+	}
+	t.store(path, e)
+
+	return resp, nil
+}
+
+// base returns the RoundTripper making the actual requests
+func (t *Transport) base() http.RoundTripper {
+	if t.Base == nil {
+		return http.DefaultTransport
+	}
+
+	return t.Base
+}
+
+// entryPath returns the path of the cache entry for req.
+//
+// Entries are keyed by the request URL, and by the headers that may change the response
+// (so responses for one token are never served to requests with another).
+func (t *Transport) entryPath(req *http.Request) string {
+	key := sha256.Sum256([]byte(strings.Join([]string{
+		req.URL.String(),
+		req.Header.Get("Accept"),
+		req.Header.Get("Authorization"),
+		req.Header.Get("Private-Token"),
+		req.Header.Get("Job-Token"),
+	}, "\n")))
+
+	return filepath.Join(t.Dir, fmt.Sprintf("%x.json", key))
+}
+
+// load reads the cache entry at path, returning nil if there is none
+func (t *Transport) load(path string) (*entry, error) {
+	data, err := os.ReadFile(path)
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	e := entry{}
+	err = json.Unmarshal(data, &e)
+	if err != nil {
+		return nil, err
+	}
+
+	return &e, nil
+}
+
+// store writes the cache entry e to path, replacing it atomically. Failing to cache
+// is not an error: it is just logged.
+func (t *Transport) store(path string, e *entry) {
+	err := t.write(path, e)
+	if err != nil {
+		log.Printf("error writing http cache entry %s: %s", path, err)
+	}
+}
+
+func (t *Transport) write(path string, e *entry) error {
+	data, err := json.Marshal(e)
+	if err != nil {
+		return err
+	}
+
+	err = os.MkdirAll(t.Dir, 0o755)
+	if err != nil {
+		return err
+	}
+
+	f, err := os.CreateTemp(t.Dir, filepath.Base(path)+".*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(f.Name())
+
+	_, err = f.Write(data)
+	if err != nil {
+		f.Close()
+		return err
+	}
+	err = f.Close()
+	if err != nil {
+		return err
+	}
+
+	return os.Rename(f.Name(), path)
+}
+
+// response returns the cached response to req, with header (if any, like from a 304
+// response) merged over the cached headers
+func (e *entry) response(req *http.Request, header http.Header) *http.Response {
+	h := e.Header.Clone()
+	for k, v := range header {
+		h[k] = v
+	}
+	h.Set("X-From-Cache", "1")
+
+	return &http.Response{
+		Status:        "200 OK",
+		StatusCode:    http.StatusOK,
+		Proto:         "HTTP/1.1",
+		ProtoMajor:    1,
+		ProtoMinor:    1,
+		Header:        h,
+		Body:          io.NopCloser(bytes.NewReader(e.Body)),
+		ContentLength: int64(len(e.Body)),
+		Request:       req,
+	}
+}
+
+// cacheable tells if resp is worth caching: a successful JSON response
+func cacheable(resp *http.Response) bool {
+	if resp.StatusCode != http.StatusOK {
+		return false
+	}
+
+	mediaType, _, err := mime.ParseMediaType(resp.Header.Get("Content-Type"))
+	if err != nil {
+		return false
+	}
+
+	return mediaType == "application/json" || strings.HasSuffix(mediaType, "+json")
+}
+
+// readCloser reads from r, closing c
+type readCloser struct {
+	io.Reader
+	c io.Closer
+}
+
+func (r readCloser) Close() error {
+	return r.c.Close()
+}
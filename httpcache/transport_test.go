@@ -0,0 +1,181 @@
+package httpcache_test
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/dgmorales/go-cli-selfupdate/httpcache"
+	"github.com/dgmorales/go-cli-selfupdate/logger"
+)
+
+const (
+	fakeETag    = `"v3"`
+	fakeRelease = `{"tag_name":"v3"}`
+)
+
+func TestMain(m *testing.M) {
+	// disable output of log during testing to not pollute test output
+	logger.SetUp(false)
+	os.Exit(m.Run())
+}
+
+// newReleaseServer starts a server answering with fakeRelease (as JSON) on /release, and
+// with plain text on /asset, counting requests by how they were answered
+func newReleaseServer(t *testing.T) (srv *httptest.Server, full *int32, notModified *int32) {
+	t.Helper()
+
+	full, notModified = new(int32), new(int32)
+	srv = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/release":
+			w.Header().Set("Etag", fakeETag)
+			if r.Header.Get("If-None-Match") == fakeETag {
+				atomic.AddInt32(notModified, 1)
+				w.WriteHeader(http.StatusNotModified)
+				return
+			}
+			atomic.AddInt32(full, 1)
+			w.Header().Set("Content-Type", "application/json; charset=utf-8")
+			fmt.Fprint(w, fakeRelease)
+
+		case "/asset":
+			atomic.AddInt32(full, 1)
+			w.Header().Set("Content-Type", "application/octet-stream")
+			fmt.Fprint(w, "binary")
+
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	t.Cleanup(srv.Close)
+
+	return srv, full, notModified
+}
+
+// get gets url with client, failing the test unless it answers 200 with want
+func get(t *testing.T, client *http.Client, url string, want string) {
+	t.Helper()
+
+	resp, err := client.Get(url)
+	if err != nil {
+		t.Fatalf("expected nil error, got %s", err)
+	}
+	defer resp.Body.Close()
+
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		t.Fatalf("expected nil error reading body, got %s", err)
+	}
+	if resp.StatusCode != http.StatusOK || string(body) != want {
+		t.Errorf("expected 200 %s, got %s %s", want, resp.Status, body)
+	}
+}
+
+func TestTransportServesFreshResponsesFromCache(t *testing.T) {
+	srv, full, notModified := newReleaseServer(t)
+	dir := t.TempDir()
+
+	// each run has its own client, sharing the cache dir
+	for i := 0; i < 3; i++ {
+		client := &http.Client{Transport: &httpcache.Transport{Dir: dir, TTL: time.Hour}}
+		get(t, client, srv.URL+"/release", fakeRelease)
+	}
+
+	if *full != 1 || *notModified != 0 {
+		t.Errorf("expected 1 request, got %d full and %d conditional ones", *full, *notModified)
+	}
+}
+
+func TestTransportRevalidatesStaleResponses(t *testing.T) {
+	srv, full, notModified := newReleaseServer(t)
+	client := &http.Client{Transport: &httpcache.Transport{Dir: t.TempDir(), TTL: 0}}
+
+	for i := 0; i < 3; i++ {
+		get(t, client, srv.URL+"/release", fakeRelease)
+	}
+
+	if *full != 1 || *notModified != 2 {
+		t.Errorf("expected 1 full request and 2 conditional ones, got %d and %d", *full, *notModified)
+	}
+}
+
+func TestTransportDoesNotCacheOtherResponses(t *testing.T) {
+	srv, full, _ := newReleaseServer(t)
+	client := &http.Client{Transport: &httpcache.Transport{Dir: t.TempDir(), TTL: time.Hour}}
+
+	for i := 0; i < 2; i++ {
+		get(t, client, srv.URL+"/asset", "binary")
+	}
+
+	if *full != 2 {
+		t.Errorf("expected 2 requests for a non-JSON response, got %d", *full)
+	}
+}
+
+func TestTransportKeysResponsesByAuthorization(t *testing.T) {
+	srv, full, _ := newReleaseServer(t)
+	dir := t.TempDir()
+
+	for _, token := range []string{"", "some-token", "other-token"} {
+		client := &http.Client{Transport: &httpcache.Transport{Dir: dir, TTL: time.Hour}}
+		req, err := http.NewRequest(http.MethodGet, srv.URL+"/release", nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if token != "" {
+			req.Header.Set("Authorization", "Bearer "+token)
+		}
+
+		resp, err := client.Do(req)
+		if err != nil {
+			t.Fatalf("expected nil error, got %s", err)
+		}
+		resp.Body.Close()
+	}
+
+	if *full != 3 {
+		t.Errorf("expected a request for each token, got %d", *full)
+	}
+}
+
+func TestTransportIsSafeForConcurrentRuns(t *testing.T) {
+	srv, _, _ := newReleaseServer(t)
+	dir := t.TempDir()
+
+	bodies := make(chan string, 50)
+	var wg sync.WaitGroup
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+
+			// TTL 0 makes every run revalidate and rewrite the entry
+			client := &http.Client{Transport: &httpcache.Transport{Dir: dir, TTL: 0}}
+			for j := 0; j < 5; j++ {
+				resp, err := client.Get(srv.URL + "/release")
+				if err != nil {
+					bodies <- err.Error()
+					continue
+				}
+				body, _ := io.ReadAll(resp.Body)
+				resp.Body.Close()
+				bodies <- string(body)
+			}
+		}()
+	}
+	wg.Wait()
+	close(bodies)
+
+	for body := range bodies {
+		if body != fakeRelease {
+			t.Errorf("expected %s, got %s", fakeRelease, body)
+		}
+	}
+}
@@ -0,0 +1,186 @@
+package oci
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+	"sync"
+)
+
+// authTransport authenticates requests going to an OCI registry host.
+//
+// It supports static bearer tokens, basic auth, and the registry token authentication
+// flow: when the registry answers 401 with a Bearer challenge, a token is requested from
+// the challenge realm (using basic auth credentials, if any) and the request is retried
+// with it.
+//
+// Requests to other hosts (like blob storage the registry redirects to) are sent
+// without credentials.
+type authTransport struct {
+	host     string
+	username string
+	password string
+	base     http.RoundTripper
+
+	mu    sync.Mutex
+	token string
+}
+
+func (t *authTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	if req.URL.Host != t.host {
+		return t.base.RoundTrip(req)
+	}
+
+	t.mu.Lock()
+	token := t.token
+	t.mu.Unlock()
+
+	resp, err := t.base.RoundTrip(t.authorized(req, token))
+	if err != nil || resp.StatusCode != http.StatusUnauthorized {
+		return resp, err
+	}
+
+	scheme, params := parseChallenge(resp.Header.Get("WWW-Authenticate"))
+	if scheme != "bearer" || params["realm"] == "" {
+		return resp, nil
+	}
+	resp.Body.Close()
+
+	token, err = t.fetchToken(req.Context(), params)
+	if err != nil {
+		return nil, err
+	}
+
+	t.mu.Lock()
+	t.token = token
+	t.mu.Unlock()
+
+	return t.base.RoundTrip(t.authorized(req, token))
+}
+
+// authorized returns a copy of req with the Authorization header set, using token if set
+// and basic auth credentials otherwise
+func (t *authTransport) authorized(req *http.Request, token string) *http.Request {
+	// RoundTrippers should not modify the original request
+	r := req.Clone(req.Context())
+
+	if token != "" {
+		r.Header.Set("Authorization", "Bearer "+token)
+	} else if t.username != "" {
+		r.SetBasicAuth(t.username, t.password)
+	}
+
+	return r
+}
+
+// fetchToken gets a bearer token from the auth server in a registry challenge, giving up
+// when ctx is done
+func (t *authTransport) fetchToken(ctx context.Context, challenge map[string]string) (string, error) {
+	u, err := url.Parse(challenge["realm"])
+	if err != nil {
+		return "", fmt.Errorf("invalid registry auth realm %s: %w", challenge["realm"], err)
+	}
+
+	q := u.Query()
+	for _, k := range []string{"service", "scope"} {
+		if challenge[k] != "" {
+			q.Set(k, challenge[k])
+		}
+	}
+	u.RawQuery = q.Encode()
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, u.String(), nil)
+	if err != nil {
+		return "", err
+	}
+	if t.username != "" {
+		req.SetBasicAuth(t.username, t.password)
+	}
+
+	resp, err := t.base.RoundTrip(req)
+	if err != nil {
+		return "", fmt.Errorf("error getting registry token: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("error getting registry token: unexpected status %s", resp.Status)
+	}
+
+	var tr struct {
+		Token       string `json:"token"`
+		AccessToken string `json:"access_token"`
+	}
+	err = json.NewDecoder(resp.Body).Decode(&tr)
+	if err != nil {
+		return "", fmt.Errorf("error parsing registry token: %w", err)
+	}
+
+	if tr.Token != "" {
+		return tr.Token, nil
+	}
+	return tr.AccessToken, nil
+}
+
+// parseChallenge parses a WWW-Authenticate header like:
+//
+//	Bearer realm="https://auth.example.com/token",service="registry.example.com",scope="repository:team/cli:pull"
+//
+// It returns the lower cased scheme and the parameters.
+func parseChallenge(h string) (scheme string, params map[string]string) {
+	params = map[string]string{}
+
+	scheme, rest, _ := strings.Cut(strings.TrimSpace(h), " ")
+	scheme = strings.ToLower(scheme)
+
+	for rest != "" {
+		var kv string
+		rest = strings.TrimLeft(rest, " ,")
+
+		k, v, found := strings.Cut(rest, "=")
+		if !found {
+			break
+		}
+
+		if strings.HasPrefix(v, `"`) {
+			end := strings.Index(v[1:], `"`)
+			if end < 0 {
+				break
+			}
+			kv, rest = v[1:end+1], v[end+2:]
+		} else {
+			kv, rest, _ = strings.Cut(v, ",")
+		}
+
+		params[strings.ToLower(strings.TrimSpace(k))] = kv
+	}
+
+	return scheme, params
+}
+
+// NewClient returns an http.Client for talking to the OCI registry at registryURL (like
+// https://registry.example.com).
+//
+// It authenticates with a static bearer token from OCI_REGISTRY_TOKEN if set. Otherwise
+// it authenticates as the registry asks for it, with credentials from
+// OCI_REGISTRY_USERNAME and OCI_REGISTRY_PASSWORD if set, or anonymously.
+func NewClient(registryURL string) (*http.Client, error) {
+	u, err := url.Parse(registryURL)
+	if err != nil {
+		return nil, fmt.Errorf("error parsing registry url %s: %w", registryURL, err)
+	}
+
+	t := &authTransport{
+		host:     u.Host,
+		username: os.Getenv("OCI_REGISTRY_USERNAME"),
+		password: os.Getenv("OCI_REGISTRY_PASSWORD"),
+		token:    os.Getenv("OCI_REGISTRY_TOKEN"),
+		base:     http.DefaultTransport,
+	}
+
+	return &http.Client{Transport: t}, nil
+}
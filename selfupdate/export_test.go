@@ -0,0 +1,15 @@
+package selfupdate
+
+// SetTargetPath makes Apply and Rollback update the binary at p instead of the test
+// binary
+func SetTargetPath(p string) {
+	targetPath = p
+}
+
+// SetTrustedKeys replaces the trusted keys, including the embedded ones
+func SetTrustedKeys(keys []string) {
+	trustedKeysMu.Lock()
+	defer trustedKeysMu.Unlock()
+
+	trustedKeys = keys
+}
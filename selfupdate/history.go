@@ -0,0 +1,160 @@
+package selfupdate
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/dgmorales/go-cli-selfupdate/logger"
+)
+
+// HistoryDir is where we keep the binaries replaced by self updates, so they can be
+// rolled back to without network access
+var HistoryDir = historyDir()
+
+// DefaultHistoryKeep is how many replaced binaries we keep in HistoryDir by default
+const DefaultHistoryKeep = 3
+
+// HistoryKeep is how many replaced binaries we keep in HistoryDir. Zero (or less) keeps
+// none, leaving the history as is.
+var HistoryKeep = DefaultHistoryKeep
+
+// historyIndex is the file in HistoryDir listing the kept binaries
+const historyIndex = "history.json"
+
+// ErrNoHistory means there is no replaced binary to roll back to
+var ErrNoHistory = errors.New("no previous binaries kept")
+
+// Backup is a binary replaced by a self update
+type Backup struct {
+	Version    string    `json:"version"`
+	File       string    `json:"file"` // file name in HistoryDir
+	ReplacedAt time.Time `json:"replacedAt"`
+}
+
+// Path returns the path of the kept binary
+func (b Backup) Path() string {
+	return filepath.Join(HistoryDir, b.File)
+}
+
+// historyDir returns the XDG data dir for our history, falling back to logger.WorkDir
+// if we can't tell where the user home is
+func historyDir() string {
+	dataDir := os.Getenv("XDG_DATA_HOME")
+	if dataDir == "" {
+		home, err := os.UserHomeDir()
+		if err != nil {
+			return filepath.Join(logger.WorkDir, "history")
+		}
+		dataDir = filepath.Join(home, ".local", "share")
+	}
+
+	return filepath.Join(dataDir, "go-cli-selfupdate", "history")
+}
+
+// History lists the kept binaries, most recently replaced first
+func History() ([]Backup, error) {
+	data, err := os.ReadFile(filepath.Join(HistoryDir, historyIndex))
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	var h []Backup
+	err = json.Unmarshal(data, &h)
+	if err != nil {
+		return nil, fmt.Errorf("error parsing %s: %w", historyIndex, err)
+	}
+
+	return h, nil
+}
+
+// saveHistory writes the history index, replacing it atomically
+func saveHistory(h []Backup) error {
+	data, err := json.MarshalIndent(h, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	f, err := os.CreateTemp(HistoryDir, historyIndex+".*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(f.Name())
+
+	_, err = f.Write(data)
+	if err != nil {
+		f.Close()
+		return err
+	}
+	err = f.Close()
+	if err != nil {
+		return err
+	}
+
+	return os.Rename(f.Name(), filepath.Join(HistoryDir, historyIndex))
+}
+
+// keep copies the binary at target (of version current) to HistoryDir.
+//
+// We copy instead of using minio's OldSavePath because that renames the binary, which
+// fails when HistoryDir is in another filesystem.
+func keep(target string, current string) (Backup, error) {
+	err := os.MkdirAll(HistoryDir, 0o755)
+	if err != nil {
+		return Backup{}, err
+	}
+
+	now := time.Now()
+	ext := filepath.Ext(target)
+	b := Backup{
+		Version:    current,
+		File:       fmt.Sprintf("%s-%s-%d%s", strings.TrimSuffix(filepath.Base(target), ext), current, now.UnixNano(), ext),
+		ReplacedAt: now,
+	}
+
+	src, err := os.Open(target)
+	if err != nil {
+		return Backup{}, err
+	}
+	defer src.Close()
+
+	dst, err := os.OpenFile(b.Path(), os.O_CREATE|os.O_EXCL|os.O_WRONLY, 0o755)
+	if err != nil {
+		return Backup{}, err
+	}
+
+	_, err = io.Copy(dst, src)
+	if err != nil {
+		dst.Close()
+		os.Remove(b.Path())
+		return Backup{}, err
+	}
+
+	return b, dst.Close()
+}
+
+// push adds b to the history, removing the oldest binaries beyond HistoryKeep
+func push(b Backup) error {
+	h, err := History()
+	if err != nil {
+		return err
+	}
+
+	h = append([]Backup{b}, h...)
+	if len(h) > HistoryKeep {
+		for _, old := range h[HistoryKeep:] {
+			os.Remove(old.Path())
+		}
+		h = h[:HistoryKeep]
+	}
+
+	return saveHistory(h)
+}
@@ -0,0 +1,215 @@
+package selfupdate_test
+
+import (
+	"crypto/sha256"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/dgmorales/go-cli-selfupdate/selfupdate"
+)
+
+// setUpHistory points self updates to a fake binary with content, and to an empty
+// history dir keeping keep binaries
+func setUpHistory(t *testing.T, content string, keep int) (target string) {
+	t.Helper()
+
+	dir := t.TempDir()
+	target = filepath.Join(dir, "fake-cli")
+	err := os.WriteFile(target, []byte(content), 0o755)
+	if err != nil {
+		t.Fatalf("error writing fake binary: %s", err)
+	}
+
+	oldDir, oldKeep := selfupdate.HistoryDir, selfupdate.HistoryKeep
+	selfupdate.HistoryDir = filepath.Join(dir, "history")
+	selfupdate.HistoryKeep = keep
+	selfupdate.SetTargetPath(target)
+	t.Cleanup(func() {
+		selfupdate.HistoryDir, selfupdate.HistoryKeep = oldDir, oldKeep
+		selfupdate.SetTargetPath("")
+	})
+
+	return target
+}
+
+func verifyFileContent(t *testing.T, filename string, content string) {
+	t.Helper()
+
+	data, err := os.ReadFile(filename)
+	if err != nil {
+		t.Fatalf("error reading %s: %s", filename, err)
+	}
+	if string(data) != content {
+		t.Errorf("== expected %s content:\n%s\n== got:\n%s\n", filename, content, string(data))
+	}
+}
+
+func TestApplyKeepsReplacedBinaries(t *testing.T) {
+	target := setUpHistory(t, "v1 binary", 2)
+
+	for _, v := range []string{"1.0.0", "2.0.0", "3.0.0"} {
+		err := selfupdate.Apply("testdata/test.tar.gz", v, selfupdate.Options{})
+		if err != nil {
+			t.Fatalf("expected nil error applying over %s, got %s", v, err)
+		}
+	}
+	verifyFileContent(t, target, fakeAssetContent)
+
+	h, err := selfupdate.History()
+	if err != nil {
+		t.Fatalf("expected nil error, got %s", err)
+	}
+	if len(h) != 2 || h[0].Version != "3.0.0" || h[1].Version != "2.0.0" {
+		t.Fatalf("expected history with 3.0.0 and 2.0.0, got %+v", h)
+	}
+
+	files, err := filepath.Glob(filepath.Join(selfupdate.HistoryDir, "fake-cli-*"))
+	if err != nil || len(files) != 2 {
+		t.Errorf("expected 2 kept binaries, got %v (err: %v)", files, err)
+	}
+}
+
+func TestApplyKeepsNoBinariesWithZeroKeep(t *testing.T) {
+	target := setUpHistory(t, "v1 binary", 0)
+
+	err := selfupdate.Apply("testdata/test.tar.gz", "1.0.0", selfupdate.Options{})
+	if err != nil {
+		t.Fatalf("expected nil error, got %s", err)
+	}
+	verifyFileContent(t, target, fakeAssetContent)
+
+	h, err := selfupdate.History()
+	if err != nil || len(h) != 0 {
+		t.Errorf("expected empty history, got %+v (err: %v)", h, err)
+	}
+}
+
+func TestRollback(t *testing.T) {
+	target := setUpHistory(t, "v1 binary", 3)
+
+	_, err := selfupdate.Rollback()
+	if !errors.Is(err, selfupdate.ErrNoHistory) {
+		t.Fatalf("expected ErrNoHistory without history, got %v", err)
+	}
+
+	err = selfupdate.Apply("testdata/test.tar.gz", "1.0.0", selfupdate.Options{})
+	if err != nil {
+		t.Fatalf("expected nil error on apply, got %s", err)
+	}
+
+	b, err := selfupdate.Rollback()
+	if err != nil {
+		t.Fatalf("expected nil error on rollback, got %s", err)
+	}
+	if b.Version != "1.0.0" {
+		t.Errorf("expected rollback to 1.0.0, got %s", b.Version)
+	}
+	verifyFileContent(t, target, "v1 binary")
+
+	h, err := selfupdate.History()
+	if err != nil || len(h) != 0 {
+		t.Errorf("expected empty history after rollback, got %+v (err: %v)", h, err)
+	}
+}
+
+func TestApplyFailsWithWrongChecksums(t *testing.T) {
+	wrong := sha256.Sum256([]byte("something else"))
+
+	testCases := []struct {
+		desc string
+		opts selfupdate.Options
+	}{
+		{desc: "WrongArchiveChecksum", opts: selfupdate.Options{SHA256: wrong[:]}},
+		{desc: "WrongBinaryChecksum", opts: selfupdate.Options{BinarySHA256: wrong[:]}},
+	}
+	for _, tC := range testCases {
+		t.Run(tC.desc, func(t *testing.T) {
+			target := setUpHistory(t, "v1 binary", 3)
+
+			err := selfupdate.Apply("testdata/test.tar.gz", "1.0.0", tC.opts)
+			if err == nil {
+				t.Fatalf("expected error, got nil")
+			}
+			verifyFileContent(t, target, "v1 binary")
+
+			h, err := selfupdate.History()
+			if err != nil || len(h) != 0 {
+				t.Errorf("expected empty history, got %+v (err: %v)", h, err)
+			}
+		})
+	}
+}
+
+func TestApplyWithRightChecksums(t *testing.T) {
+	target := setUpHistory(t, "v1 binary", 3)
+
+	data, err := os.ReadFile("testdata/test.tar.gz")
+	if err != nil {
+		t.Fatalf("error reading test archive: %s", err)
+	}
+	sum := sha256.Sum256(data)
+	binarySum := sha256.Sum256([]byte(fakeAssetContent))
+
+	err = selfupdate.Apply("testdata/test.tar.gz", "1.0.0", selfupdate.Options{SHA256: sum[:], BinarySHA256: binarySum[:]})
+	if err != nil {
+		t.Fatalf("expected nil error, got %s", err)
+	}
+	verifyFileContent(t, target, fakeAssetContent)
+}
+
+func TestApplyPatch(t *testing.T) {
+	old, err := os.ReadFile("testdata/patch.old")
+	if err != nil {
+		t.Fatalf("error reading test binary: %s", err)
+	}
+	patched, err := os.ReadFile("testdata/patch.new")
+	if err != nil {
+		t.Fatalf("error reading test binary: %s", err)
+	}
+	sum := sha256.Sum256(patched)
+
+	testCases := []struct {
+		desc       string
+		current    string
+		opts       selfupdate.Options
+		shouldFail bool
+	}{
+		{
+			desc:    "PatchesCurrentBinary",
+			current: string(old),
+			opts:    selfupdate.Options{Patch: true, BinarySHA256: sum[:]},
+		},
+		{
+			desc:       "FailsWithoutBinaryChecksum",
+			current:    string(old),
+			opts:       selfupdate.Options{Patch: true},
+			shouldFail: true,
+		},
+		{
+			desc:       "FailsWithAnotherCurrentBinary",
+			current:    "v1 binary",
+			opts:       selfupdate.Options{Patch: true, BinarySHA256: sum[:]},
+			shouldFail: true,
+		},
+	}
+	for _, tC := range testCases {
+		t.Run(tC.desc, func(t *testing.T) {
+			target := setUpHistory(t, tC.current, 3)
+
+			err := selfupdate.Apply("testdata/patch.bsdiff", "1.0.0", tC.opts)
+			if tC.shouldFail {
+				if err == nil {
+					t.Fatalf("expected error, got nil")
+				}
+				verifyFileContent(t, target, tC.current)
+				return
+			}
+			if err != nil {
+				t.Fatalf("expected nil error, got %s", err)
+			}
+			verifyFileContent(t, target, string(patched))
+		})
+	}
+}
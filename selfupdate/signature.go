@@ -0,0 +1,124 @@
+package selfupdate
+
+import (
+	_ "embed"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+
+	minioSelfUpdate "github.com/minio/selfupdate"
+)
+
+//go:embed trusted_keys.txt
+var embeddedKeys string
+
+// trustedKeys are the minisign public keys release binaries must be signed with. If
+// there are none, binaries are not required to be signed.
+//
+// Guarded by trustedKeysMu, as they may be added (by a background version check) while
+// verifying a binary.
+var trustedKeys = mustParseKeys(embeddedKeys)
+var trustedKeysMu sync.RWMutex
+
+// ErrUnsigned means a release binary has no signature, while we have trusted keys
+var ErrUnsigned = errors.New("release binary is not signed")
+
+// ParseKeys parses minisign public keys, one per line (or separated by ;), as in the
+// second line of a minisign .pub file. Blank lines, lines starting with # and minisign's
+// "untrusted comment:" lines are ignored.
+func ParseKeys(s string) ([]string, error) {
+	keys := []string{}
+
+	for _, line := range strings.FieldsFunc(s, func(r rune) bool { return r == '\n' || r == ';' }) {
+		line = strings.TrimSpace(line)
+		if line == "" || strings.HasPrefix(line, "#") || strings.HasPrefix(line, "untrusted comment:") {
+			continue
+		}
+
+		// minisign Ed25519 public keys are the algorithm (Ed), a key id (8 bytes) and the
+		// key itself (32 bytes)
+		bin, err := base64.StdEncoding.DecodeString(line)
+		if err != nil || len(bin) != 42 || string(bin[:2]) != "Ed" {
+			return nil, fmt.Errorf("invalid minisign public key %q", line)
+		}
+		keys = append(keys, line)
+	}
+
+	return keys, nil
+}
+
+// mustParseKeys is ParseKeys for the embedded keys, that can't be wrong in a release
+func mustParseKeys(s string) []string {
+	keys, err := ParseKeys(s)
+	if err != nil {
+		panic(fmt.Sprintf("invalid embedded trusted keys: %s", err))
+	}
+
+	return keys
+}
+
+// TrustKeys adds minisign public keys (see ParseKeys) to the ones embedded at build
+// time, like keys listed in the server side config. Keys already trusted are skipped, so
+// it is fine to call it again with the same keys.
+func TrustKeys(keys ...string) {
+	trustedKeysMu.Lock()
+	defer trustedKeysMu.Unlock()
+
+	for _, key := range keys {
+		if !isTrusted(key) {
+			trustedKeys = append(trustedKeys, key)
+		}
+	}
+}
+
+// isTrusted tells if key is among the trusted keys. Callers must hold trustedKeysMu.
+func isTrusted(key string) bool {
+	for _, k := range trustedKeys {
+		if k == key {
+			return true
+		}
+	}
+
+	return false
+}
+
+// TrustedKeys returns the minisign public keys release binaries must be signed with
+func TrustedKeys() []string {
+	trustedKeysMu.RLock()
+	defer trustedKeysMu.RUnlock()
+
+	return append([]string{}, trustedKeys...)
+}
+
+// signatureVerifier verifies binary against the minisign signature in the file signature,
+// returning the verifier for minio to check the binary again while applying it.
+//
+// It returns a nil verifier if there are no trusted keys, and ErrUnsigned if there are
+// but signature is blank.
+func signatureVerifier(binary []byte, signature string) (*minioSelfUpdate.Verifier, error) {
+	keys := TrustedKeys()
+	if len(keys) == 0 {
+		return nil, nil
+	}
+	if signature == "" {
+		return nil, ErrUnsigned
+	}
+
+	var err error
+	for _, key := range keys {
+		v := minioSelfUpdate.NewVerifier()
+		err = v.LoadFromFile(signature, key)
+		if err != nil {
+			return nil, fmt.Errorf("error loading signature %s: %w", signature, err)
+		}
+
+		err = v.Verify(binary)
+		if err == nil {
+			return v, nil
+		}
+	}
+
+	return nil, fmt.Errorf("release binary is not signed by a trusted key: %w", err)
+}
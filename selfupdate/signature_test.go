@@ -0,0 +1,164 @@
+package selfupdate_test
+
+import (
+	"crypto/ed25519"
+	"crypto/rand"
+	"encoding/base64"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/dgmorales/go-cli-selfupdate/selfupdate"
+)
+
+// newSigningKey generates a minisign key pair, returning the public key (as in a .pub
+// file) and a function that writes the minisign signature of content to a file
+func newSigningKey(t *testing.T, keyID string) (string, func(content string) string) {
+	t.Helper()
+
+	pub, priv, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatalf("error generating key: %s", err)
+	}
+
+	id := []byte(keyID)[:8]
+	key := base64.StdEncoding.EncodeToString(append(append([]byte("Ed"), id...), pub...))
+
+	sign := func(content string) string {
+		sig := ed25519.Sign(priv, []byte(content))
+		trusted := "timestamp:0"
+		global := ed25519.Sign(priv, append(append([]byte{}, sig...), trusted...))
+
+		data := "untrusted comment: test signature\n" +
+			base64.StdEncoding.EncodeToString(append(append([]byte("Ed"), id...), sig...)) + "\n" +
+			"trusted comment: " + trusted + "\n" +
+			base64.StdEncoding.EncodeToString(global) + "\n"
+
+		filename := filepath.Join(t.TempDir(), "fake-cli.minisig")
+		err := os.WriteFile(filename, []byte(data), 0o644)
+		if err != nil {
+			t.Fatalf("error writing signature: %s", err)
+		}
+
+		return filename
+	}
+
+	return key, sign
+}
+
+// trustKeys makes keys the only trusted keys, for the test
+func trustKeys(t *testing.T, keys ...string) {
+	old := selfupdate.TrustedKeys()
+	selfupdate.SetTrustedKeys(keys)
+	t.Cleanup(func() { selfupdate.SetTrustedKeys(old) })
+}
+
+func TestApplyVerifiesSignatures(t *testing.T) {
+	key, sign := newSigningKey(t, "testkey1")
+	otherKey, otherSign := newSigningKey(t, "testkey2")
+
+	testCases := []struct {
+		desc       string
+		keys       []string
+		signature  func() string
+		shouldFail bool
+	}{
+		{
+			desc:      "WithoutTrustedKeys",
+			signature: func() string { return "" },
+		},
+		{
+			desc:      "SignedByTrustedKey",
+			keys:      []string{key},
+			signature: func() string { return sign(fakeAssetContent) },
+		},
+		{
+			desc:      "SignedByAnyOfTrustedKeys",
+			keys:      []string{otherKey, key},
+			signature: func() string { return sign(fakeAssetContent) },
+		},
+		{
+			desc:       "FailsUnsigned",
+			keys:       []string{key},
+			signature:  func() string { return "" },
+			shouldFail: true,
+		},
+		{
+			desc:       "FailsSignedByUntrustedKey",
+			keys:       []string{key},
+			signature:  func() string { return otherSign(fakeAssetContent) },
+			shouldFail: true,
+		},
+		{
+			desc:       "FailsWithSignatureOfOtherBinary",
+			keys:       []string{key},
+			signature:  func() string { return sign("some other binary") },
+			shouldFail: true,
+		},
+	}
+	for _, tC := range testCases {
+		t.Run(tC.desc, func(t *testing.T) {
+			target := setUpHistory(t, "v1 binary", 3)
+			trustKeys(t, tC.keys...)
+
+			err := selfupdate.Apply("testdata/test.tar.gz", "1.0.0", selfupdate.Options{Signature: tC.signature()})
+			if tC.shouldFail {
+				if err == nil {
+					t.Fatalf("expected error, got nil")
+				}
+				verifyFileContent(t, target, "v1 binary")
+				return
+			}
+			if err != nil {
+				t.Fatalf("expected nil error, got %s", err)
+			}
+			verifyFileContent(t, target, fakeAssetContent)
+		})
+	}
+}
+
+func TestApplyRefusesUnsignedWithErrUnsigned(t *testing.T) {
+	key, _ := newSigningKey(t, "testkey1")
+	setUpHistory(t, "v1 binary", 3)
+	trustKeys(t, key)
+
+	err := selfupdate.Apply("testdata/test.tar.gz", "1.0.0", selfupdate.Options{})
+	if !errors.Is(err, selfupdate.ErrUnsigned) {
+		t.Errorf("expected ErrUnsigned, got %v", err)
+	}
+}
+
+func TestParseKeys(t *testing.T) {
+	key, _ := newSigningKey(t, "testkey1")
+	otherKey, _ := newSigningKey(t, "testkey2")
+
+	keys, err := selfupdate.ParseKeys("untrusted comment: minisign public key\n" + key + "\n\n# old key\n;" + otherKey)
+	if err != nil {
+		t.Fatalf("expected nil error, got %s", err)
+	}
+	if len(keys) != 2 || keys[0] != key || keys[1] != otherKey {
+		t.Errorf("expected keys [%s %s], got %v", key, otherKey, keys)
+	}
+
+	for _, invalid := range []string{"not-base64!", base64.StdEncoding.EncodeToString([]byte("too short"))} {
+		_, err := selfupdate.ParseKeys(invalid)
+		if err == nil {
+			t.Errorf("expected error parsing %q, got nil", invalid)
+		}
+	}
+}
+
+func TestTrustKeysSkipsTrustedKeys(t *testing.T) {
+	key, _ := newSigningKey(t, "testkey1")
+	otherKey, _ := newSigningKey(t, "testkey2")
+	trustKeys(t, key)
+
+	selfupdate.TrustKeys(key, otherKey)
+	selfupdate.TrustKeys(otherKey)
+
+	keys := selfupdate.TrustedKeys()
+	if len(keys) != 2 || keys[0] != key || keys[1] != otherKey {
+		t.Errorf("expected keys [%s %s], got %v", key, otherKey, keys)
+	}
+}
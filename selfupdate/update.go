@@ -1,24 +1,227 @@
 package selfupdate
 
 import (
+	"bytes"
+	"crypto"
+	"crypto/sha256"
+	"fmt"
+	"io"
+	"log"
+	"os"
+
 	minioSelfUpdate "github.com/minio/selfupdate"
 )
 
-func Apply(filename string) error {
+// targetPath is the binary we update. Blank means the running executable.
+var targetPath string
 
-	reader, closer, err := Uncompress(filename)
+// Options are the checksums and signature a release was published with, for Apply to
+// check before touching the running binary. Nil checksums are not checked.
+type Options struct {
+	// SHA256 is the checksum of the release archive
+	SHA256 []byte
+
+	// BinarySHA256 is the checksum of the binary in the release archive
+	BinarySHA256 []byte
+
+	// Signature is the file with the minisign signature of the binary in the release
+	// archive, or blank if there is none. It is required if there are trusted keys (see
+	// TrustedKeys).
+	Signature string
+
+	// Patch means the release file is a bsdiff patch from the running binary to the
+	// release binary, instead of an archive. BinarySHA256 is required then, to verify the
+	// patched binary.
+	Patch bool
+}
+
+// Apply replaces the running binary with the one in filename (an archive, see
+// Uncompress, or a patch, see Options.Patch).
+//
+// The archive (or patch) and the binary in it are checked against opts first. Then minio
+// checks the new binary against the published binary checksum while writing it, if there
+// is one. Otherwise, it checks it against the checksum of the extracted binary, which only
+// guards the write itself.
+//
+// If there are trusted keys (see TrustedKeys), the binary must be signed with one of them.
+// Unsigned (or wrongly signed) binaries are refused.
+//
+// The replaced binary, of version current, is kept in HistoryDir for rollbacks (unless
+// HistoryKeep is zero).
+func Apply(filename string, current string, opts Options) error {
+	if opts.SHA256 != nil {
+		sum, err := fileSHA256(filename)
+		if err != nil {
+			return err
+		}
+		if !bytes.Equal(sum, opts.SHA256) {
+			return fmt.Errorf("release file %s has wrong sha256 checksum %x (expected %x)", filename, sum, opts.SHA256)
+		}
+	}
+
+	target, err := targetBinary()
 	if err != nil {
-		if closer != nil {
-			closer()
+		return err
+	}
+
+	var binary []byte
+	if opts.Patch {
+		if opts.BinarySHA256 == nil {
+			return fmt.Errorf("refusing to apply patch %s without a release binary checksum to verify it", filename)
 		}
+		binary, err = patchBinary(target, filename)
+	} else {
+		binary, err = extractBinary(filename)
+	}
+	if err != nil {
 		return err
 	}
-	defer closer()
 
-	err = minioSelfUpdate.Apply(reader, minioSelfUpdate.Options{})
+	sum := sha256.Sum256(binary)
+	if opts.BinarySHA256 != nil && !bytes.Equal(sum[:], opts.BinarySHA256) {
+		return fmt.Errorf("binary from release file %s has wrong sha256 checksum %x (expected %x)", filename, sum, opts.BinarySHA256)
+	}
+
+	verifier, err := signatureVerifier(binary, opts.Signature)
 	if err != nil {
+		return fmt.Errorf("refusing to apply release file %s: %w", filename, err)
+	}
+
+	var b Backup
+	if HistoryKeep > 0 {
+		b, err = keep(target, current)
+		if err != nil {
+			return fmt.Errorf("error keeping current binary for rollback: %w", err)
+		}
+	}
+
+	checksum := opts.BinarySHA256
+	if checksum == nil {
+		checksum = sum[:]
+	}
+
+	err = minioSelfUpdate.Apply(bytes.NewReader(binary), minioSelfUpdate.Options{
+		TargetPath: target,
+		Hash:       crypto.SHA256,
+		Checksum:   checksum,
+		Verifier:   verifier,
+	})
+	if err != nil {
+		if HistoryKeep > 0 {
+			os.Remove(b.Path())
+		}
 		return err
 	}
 
+	if HistoryKeep > 0 {
+		err = push(b)
+		if err != nil {
+			// the update is done anyway, so we won't fail because of this
+			log.Printf("error saving %s to self update history: %s", b.Path(), err)
+		}
+	}
+
 	return nil
 }
+
+// Rollback replaces the running binary with the most recently replaced one, and removes
+// it from the history, returning it.
+//
+// It returns ErrNoHistory if there is no binary to roll back to.
+func Rollback() (Backup, error) {
+	h, err := History()
+	if err != nil {
+		return Backup{}, err
+	}
+	if len(h) == 0 {
+		return Backup{}, ErrNoHistory
+	}
+	b := h[0]
+
+	target, err := targetBinary()
+	if err != nil {
+		return b, err
+	}
+
+	f, err := os.Open(b.Path())
+	if err != nil {
+		return b, err
+	}
+
+	err = minioSelfUpdate.Apply(f, minioSelfUpdate.Options{TargetPath: target})
+	f.Close()
+	if err != nil {
+		return b, err
+	}
+
+	err = saveHistory(h[1:])
+	if err != nil {
+		return b, err
+	}
+	os.Remove(b.Path())
+
+	return b, nil
+}
+
+// extractBinary reads the binary in the release archive filename
+func extractBinary(filename string) ([]byte, error) {
+	reader, closer, err := Uncompress(filename)
+	if closer != nil {
+		defer closer()
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	return io.ReadAll(reader)
+}
+
+// patchBinary applies the bsdiff patch in the file patch to the binary at target,
+// returning the patched binary
+func patchBinary(target string, patch string) ([]byte, error) {
+	old, err := os.Open(target)
+	if err != nil {
+		return nil, err
+	}
+	defer old.Close()
+
+	p, err := os.Open(patch)
+	if err != nil {
+		return nil, err
+	}
+	defer p.Close()
+
+	var binary bytes.Buffer
+	err = minioSelfUpdate.NewBSDiffPatcher().Patch(old, &binary, p)
+	if err != nil {
+		return nil, fmt.Errorf("error applying patch %s: %w", patch, err)
+	}
+
+	return binary.Bytes(), nil
+}
+
+// fileSHA256 returns the SHA-256 checksum of the file filename
+func fileSHA256(filename string) ([]byte, error) {
+	f, err := os.Open(filename)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	h := sha256.New()
+	_, err = io.Copy(h, f)
+	if err != nil {
+		return nil, err
+	}
+
+	return h.Sum(nil), nil
+}
+
+// targetBinary returns the path of the binary we update
+func targetBinary() (string, error) {
+	if targetPath != "" {
+		return targetPath, nil
+	}
+
+	return os.Executable()
+}
@@ -0,0 +1,8 @@
+package start
+
+import "github.com/dgmorales/go-cli-selfupdate/version"
+
+// SaveLastCheck exports saveLastCheck for tests
+func SaveLastCheck(v version.Checker) {
+	saveLastCheck(v)
+}
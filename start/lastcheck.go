@@ -0,0 +1,106 @@
+package start
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+
+	"github.com/dgmorales/go-cli-selfupdate/logger"
+	"github.com/dgmorales/go-cli-selfupdate/version"
+	semver "github.com/hashicorp/go-version"
+)
+
+// LastCheckFile is where we keep the result of the last version check, for commands that
+// check in the background to enforce it (and tell the user about it) without waiting for
+// a new one
+var LastCheckFile = lastCheckFile()
+
+// lastCheckFile returns the file for the last check result in the XDG cache dir, falling
+// back to logger.WorkDir if we can't tell where the user cache is
+func lastCheckFile() string {
+	cacheDir, err := os.UserCacheDir()
+	if err != nil {
+		return filepath.Join(logger.WorkDir, "last-check.json")
+	}
+
+	return filepath.Join(cacheDir, "go-cli-selfupdate", "last-check.json")
+}
+
+// LastCheck returns the result of the last version check of the running version, or nil
+// if there is none
+func LastCheck() (*version.Result, error) {
+	data, err := os.ReadFile(LastCheckFile)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	r := version.Result{}
+	err = json.Unmarshal(data, &r)
+	if err != nil {
+		return nil, fmt.Errorf("error parsing %s: %w", LastCheckFile, err)
+	}
+
+	// checks of other versions (like before a self update) tell nothing about this one
+	checked, err := semver.NewSemver(r.Current)
+	if err != nil {
+		return nil, fmt.Errorf("error parsing %s: %w", LastCheckFile, err)
+	}
+	current, err := semver.NewSemver(version.Current)
+	if err != nil || !checked.Equal(current) {
+		return nil, nil
+	}
+
+	return &r, nil
+}
+
+// saveLastCheck keeps the result of checking v, unless it is unknown (which would hide
+// the last known one). It replaces the last one atomically, as concurrent runs may be
+// saving theirs too. Failing to save is not an error: it is just logged.
+func saveLastCheck(v version.Checker) {
+	r := version.Snapshot(v)
+	if r.Assertion == version.IsUnknown {
+		return
+	}
+
+	err := writeLastCheck(r)
+	if err != nil {
+		log.Printf("error saving version check result to %s: %s", LastCheckFile, err)
+	}
+}
+
+func writeLastCheck(r version.Result) error {
+	data, err := json.MarshalIndent(r, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	dir := filepath.Dir(LastCheckFile)
+	err = os.MkdirAll(dir, 0o755)
+	if err != nil {
+		return err
+	}
+
+	f, err := os.CreateTemp(dir, filepath.Base(LastCheckFile)+".*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(f.Name())
+
+	_, err = f.Write(data)
+	if err != nil {
+		f.Close()
+		return err
+	}
+	err = f.Close()
+	if err != nil {
+		return err
+	}
+
+	return os.Rename(f.Name(), LastCheckFile)
+}
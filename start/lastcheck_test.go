@@ -0,0 +1,94 @@
+package start_test
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/dgmorales/go-cli-selfupdate/logger"
+	"github.com/dgmorales/go-cli-selfupdate/start"
+	"github.com/dgmorales/go-cli-selfupdate/version"
+)
+
+func TestMain(m *testing.M) {
+	// disable output of log during testing to not pollute test output
+	logger.SetUp(false)
+	os.Exit(m.Run())
+}
+
+// newReleaseDir creates a local release directory with a release of each of versions
+func newReleaseDir(t *testing.T, versions ...string) string {
+	t.Helper()
+
+	dir := t.TempDir()
+	for _, v := range versions {
+		err := os.Mkdir(filepath.Join(dir, v), 0o755)
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	return dir
+}
+
+// useLastCheckFile points start.LastCheckFile to a temporary file for the test
+func useLastCheckFile(t *testing.T) {
+	t.Helper()
+
+	old := start.LastCheckFile
+	start.LastCheckFile = filepath.Join(t.TempDir(), "last-check.json")
+	t.Cleanup(func() { start.LastCheckFile = old })
+}
+
+func TestLastCheckIsSavedForTheRunningVersion(t *testing.T) {
+	useLastCheckFile(t)
+
+	r, err := start.LastCheck()
+	if err != nil || r != nil {
+		t.Fatalf("expected no last check before any, got %v (err: %v)", r, err)
+	}
+
+	v, err := version.NewLocalDirChecker(newReleaseDir(t, "v99.0.0"), version.Policy{MinimalRequired: "v98.0.0"}, version.Current)
+	if err != nil {
+		t.Fatalf("expected nil error, got %s", err)
+	}
+	start.SaveLastCheck(v)
+
+	r, err = start.LastCheck()
+	if err != nil || r == nil {
+		t.Fatalf("expected last check, got %v (err: %v)", r, err)
+	}
+	if r.Assertion != version.MustUpdate || r.Latest != "99.0.0" || r.Minimal != "98.0.0" {
+		t.Errorf("expected MustUpdate to 99.0.0 (minimal 98.0.0), got %d to %s (minimal %s)", r.Assertion, r.Latest, r.Minimal)
+	}
+}
+
+func TestLastCheckIgnoresOtherVersions(t *testing.T) {
+	useLastCheckFile(t)
+
+	v, err := version.NewLocalDirChecker(newReleaseDir(t, "v99.0.0"), version.Policy{}, "v0.0.1")
+	if err != nil {
+		t.Fatalf("expected nil error, got %s", err)
+	}
+	start.SaveLastCheck(v)
+
+	r, err := start.LastCheck()
+	if err != nil || r != nil {
+		t.Errorf("expected no last check for the running version, got %v (err: %v)", r, err)
+	}
+}
+
+func TestLastCheckDoesNotSaveUnknownResults(t *testing.T) {
+	useLastCheckFile(t)
+
+	// no releases at all
+	v, err := version.NewLocalDirChecker(newReleaseDir(t), version.Policy{}, version.Current)
+	if err != nil {
+		t.Fatalf("expected nil error, got %s", err)
+	}
+	start.SaveLastCheck(v)
+
+	if _, err := os.Stat(start.LastCheckFile); !os.IsNotExist(err) {
+		t.Errorf("expected no last check file for an unknown result, got %v", err)
+	}
+}
@@ -0,0 +1,99 @@
+package start
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/dgmorales/go-cli-selfupdate/logger"
+)
+
+// MachineIDFile is where we keep the random ID identifying this machine (or user
+// account) for staged rollouts. It is in the user config dir rather than the cache dir,
+// as a new ID may move the machine out of a rollout it was in.
+var MachineIDFile = machineIDFile()
+
+// machineIDFile returns the machine ID file in the XDG config dir, falling back to
+// logger.WorkDir if we can't tell where the user config is
+func machineIDFile() string {
+	configDir, err := os.UserConfigDir()
+	if err != nil {
+		return filepath.Join(logger.WorkDir, "machine-id")
+	}
+
+	return filepath.Join(configDir, "go-cli-selfupdate", "machine-id")
+}
+
+// MachineID returns the ID identifying this machine for staged rollouts, creating it on
+// first use. It is random, telling nothing about the machine.
+func MachineID() (string, error) {
+	id, err := readMachineID()
+	if !errors.Is(err, os.ErrNotExist) {
+		return id, err
+	}
+
+	err = createMachineID()
+	if err != nil {
+		return "", err
+	}
+
+	return readMachineID()
+}
+
+func readMachineID() (string, error) {
+	data, err := os.ReadFile(MachineIDFile)
+	if err != nil {
+		return "", err
+	}
+
+	id := strings.TrimSpace(string(data))
+	if id == "" {
+		return "", fmt.Errorf("empty machine id in %s", MachineIDFile)
+	}
+
+	return id, nil
+}
+
+// createMachineID creates the machine ID file with a new random ID, unless a concurrent
+// run just did it (in which case we keep theirs)
+func createMachineID() error {
+	b := make([]byte, 16)
+	_, err := rand.Read(b)
+	if err != nil {
+		return err
+	}
+
+	dir := filepath.Dir(MachineIDFile)
+	err = os.MkdirAll(dir, 0o755)
+	if err != nil {
+		return err
+	}
+
+	f, err := os.CreateTemp(dir, filepath.Base(MachineIDFile)+".*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(f.Name())
+
+	_, err = fmt.Fprintln(f, hex.EncodeToString(b))
+	if err != nil {
+		f.Close()
+		return err
+	}
+	err = f.Close()
+	if err != nil {
+		return err
+	}
+
+	// unlike rename, link never replaces an ID some other run created meanwhile
+	err = os.Link(f.Name(), MachineIDFile)
+	if errors.Is(err, os.ErrExist) {
+		return nil
+	}
+
+	return err
+}
@@ -0,0 +1,24 @@
+package start_test
+
+import (
+	"path/filepath"
+	"testing"
+
+	"github.com/dgmorales/go-cli-selfupdate/start"
+)
+
+func TestMachineIDIsCreatedOnceAndKept(t *testing.T) {
+	old := start.MachineIDFile
+	start.MachineIDFile = filepath.Join(t.TempDir(), "config", "machine-id")
+	t.Cleanup(func() { start.MachineIDFile = old })
+
+	id, err := start.MachineID()
+	if err != nil || id == "" {
+		t.Fatalf("expected a machine id, got %q (err: %v)", id, err)
+	}
+
+	again, err := start.MachineID()
+	if err != nil || again != id {
+		t.Errorf("expected machine id %q to be kept, got %q (err: %v)", id, again, err)
+	}
+}
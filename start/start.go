@@ -1,15 +1,58 @@
 package start
 
 import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"path"
+	"strconv"
+	"strings"
+	"time"
+
 	"github.com/dgmorales/go-cli-selfupdate/config"
 	"github.com/dgmorales/go-cli-selfupdate/gh"
+	"github.com/dgmorales/go-cli-selfupdate/gitea"
+	"github.com/dgmorales/go-cli-selfupdate/gl"
+	"github.com/dgmorales/go-cli-selfupdate/httpcache"
 	"github.com/dgmorales/go-cli-selfupdate/kube"
 	"github.com/dgmorales/go-cli-selfupdate/logger"
+	"github.com/dgmorales/go-cli-selfupdate/oci"
+	"github.com/dgmorales/go-cli-selfupdate/selfupdate"
 	"github.com/dgmorales/go-cli-selfupdate/version"
 	"github.com/google/go-github/v48/github"
 	"k8s.io/client-go/kubernetes"
 )
 
+const defGitLabURL = "https://gitlab.com"
+
+// Options are local choices (usually from command line flags) affecting how we start
+type Options struct {
+	Debug bool
+
+	// Channel overrides the release channel from server side config, if not blank
+	Channel string
+
+	// CheckTimeout limits how long we take to load the server side config and find out
+	// the latest version, if not zero. Failing to find out the latest version in time
+	// leaves it unknown.
+	CheckTimeout time.Duration
+
+	// CacheTTL is for how long release lookups cached on disk are used without asking
+	// the release source again (see httpcache.Transport). Only GitHub lookups are cached.
+	CacheTTL time.Duration
+
+	// IgnoreRollout offers us the latest release even if a staged rollout in the server
+	// side config does not include us yet
+	IgnoreRollout bool
+
+	// Background is for checking our version along a command, which sets up everything
+	// global (logging, trusted keys) on its own: ForAPIUse leaves all that alone, not to
+	// race with the command.
+	Background bool
+}
+
 type State struct {
 	Version     version.Checker
 	ServerCfg   config.ServerSideConfig
@@ -18,15 +61,29 @@ type State struct {
 	ssCfgLoader config.ServerSideConfigLoader
 }
 
-func ForAPIUse(debug bool) (State, error) {
+// ConfigSource tells where the server side config was loaded from, or blank if it was
+// not loaded
+func (s *State) ConfigSource() string {
+	if s.ssCfgLoader == nil {
+		return ""
+	}
+	return s.ssCfgLoader.Source()
+}
+
+// ForAPIUse sets up everything needed to talk to our API servers, checking our version
+// against the latest release. It gives up when ctx is done.
+func ForAPIUse(ctx context.Context, opts Options) (State, error) {
 	var err error
 
-	logger.SetUp(debug)
+	if !opts.Background {
+		logger.SetUp(opts.Debug)
+	}
 	s := State{}
 
-	s.Github, err = gh.NewClient()
-	if err != nil {
-		return State{}, err
+	if opts.CheckTimeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, opts.CheckTimeout)
+		defer cancel()
 	}
 
 	s.Kube, err = kube.NewClient()
@@ -39,24 +96,191 @@ func ForAPIUse(debug bool) (State, error) {
 		return State{}, err
 	}
 
-	s.ServerCfg, err = s.ssCfgLoader.Load()
+	s.ServerCfg, err = s.ssCfgLoader.Load(ctx)
 	if err != nil {
 		return State{}, err
 	}
 
-	s.Version, err = version.NewGithubChecker(
-		s.Github,
-		s.ServerCfg.RepoOwner,
-		s.ServerCfg.RepoName,
-		s.ServerCfg.MinimalRequiredVersion,
-		version.Current)
+	keys, err := selfupdate.ParseKeys(s.ServerCfg.TrustedKeys)
+	if err != nil {
+		return State{}, fmt.Errorf("error in server side config TrustedKeys: %w", err)
+	}
+	if !opts.Background {
+		selfupdate.TrustKeys(keys...)
+	}
+
+	s.Version, err = s.newChecker(ctx, opts)
 	if err != nil {
 		return State{}, err
 	}
+	saveLastCheck(s.Version)
 
 	return s, nil
 }
 
+// newChecker returns the version.Checker for the release source set in server side config
+func (s *State) newChecker(ctx context.Context, opts Options) (version.Checker, error) {
+	var err error
+	cfg := s.ServerCfg
+
+	channelName := cfg.Channel
+	if opts.Channel != "" {
+		channelName = opts.Channel
+	}
+	channel, err := version.ParseChannel(channelName)
+	if err != nil {
+		return nil, err
+	}
+
+	policy := version.Policy{
+		MinimalRequired: cfg.MinimalRequiredVersion,
+		MaximalAllowed:  cfg.MaximalAllowedVersion,
+		Pinned:          cfg.PinnedVersion,
+		Revoked: strings.FieldsFunc(cfg.RevokedVersions, func(r rune) bool {
+			return r == '\n' || r == ';'
+		}),
+		AssetPattern: cfg.AssetPattern,
+		Constraint:   cfg.VersionConstraint,
+	}
+
+	if !opts.IgnoreRollout {
+		policy.Rollout, err = rollout(cfg)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	switch cfg.ReleaseSource {
+	case "", config.SourceGitHub:
+		s.Github, err = gh.NewClient(&httpcache.Transport{Dir: httpcache.DefaultDir(), TTL: opts.CacheTTL})
+		if err != nil {
+			return nil, err
+		}
+
+		return version.NewGithubChannelChecker(
+			ctx,
+			s.Github,
+			cfg.RepoOwner,
+			cfg.RepoName,
+			channel,
+			policy,
+			version.Current)
+
+	case config.SourceGitLab:
+		baseURL := cfg.ReleaseURL
+		if baseURL == "" {
+			baseURL = defGitLabURL
+		}
+
+		client, err := gl.NewClient(baseURL)
+		if err != nil {
+			return nil, err
+		}
+
+		return version.NewGitLabChecker(
+			ctx,
+			client,
+			baseURL,
+			path.Join(cfg.RepoOwner, cfg.RepoName),
+			policy,
+			version.Current)
+
+	case config.SourceGitea:
+		client, err := gitea.NewClient(cfg.ReleaseURL)
+		if err != nil {
+			return nil, err
+		}
+
+		return version.NewGiteaChecker(
+			ctx,
+			client,
+			cfg.ReleaseURL,
+			cfg.RepoOwner,
+			cfg.RepoName,
+			policy,
+			version.Current)
+
+	case config.SourceHTTP:
+		return version.NewHTTPManifestChecker(
+			ctx,
+			nil,
+			cfg.ReleaseURL,
+			policy,
+			version.Current)
+
+	case config.SourceLocal:
+		return version.NewLocalDirChecker(
+			cfg.ReleaseURL,
+			policy,
+			version.Current)
+
+	case config.SourceOCI:
+		registryURL := cfg.ReleaseURL
+		if registryURL != "" && !strings.Contains(registryURL, "://") {
+			registryURL = "https://" + registryURL
+		}
+
+		client, err := oci.NewClient(registryURL)
+		if err != nil {
+			return nil, err
+		}
+
+		return version.NewOCIChecker(
+			ctx,
+			client,
+			registryURL,
+			path.Join(cfg.RepoOwner, cfg.RepoName),
+			policy,
+			version.Current)
+	}
+
+	return nil, fmt.Errorf("unknown release source %q in server side config", cfg.ReleaseSource)
+}
+
+// rollout returns the staged rollout set in server side config, or nil if there is none
+func rollout(cfg config.ServerSideConfig) (*version.Rollout, error) {
+	var err error
+	r := version.Rollout{}
+
+	percent := strings.TrimSpace(cfg.RolloutPercent)
+	if percent == "" {
+		return nil, nil
+	}
+	r.Percent, err = strconv.ParseFloat(percent, 64)
+	if err != nil || r.Percent < 0 || r.Percent > 100 {
+		return nil, fmt.Errorf("invalid RolloutPercent %q in server side config, expected 0 to 100", cfg.RolloutPercent)
+	}
+
+	if start := strings.TrimSpace(cfg.RolloutStart); start != "" {
+		r.Start, err = time.Parse(time.RFC3339, start)
+		if err != nil {
+			return nil, fmt.Errorf("invalid RolloutStart in server side config: %w", err)
+		}
+	}
+
+	if ramp := strings.TrimSpace(cfg.RolloutRamp); ramp != "" {
+		r.Ramp, err = time.ParseDuration(ramp)
+		if err != nil {
+			return nil, fmt.Errorf("invalid RolloutRamp in server side config: %w", err)
+		}
+		if r.Start.IsZero() {
+			return nil, errors.New("RolloutRamp in server side config requires RolloutStart")
+		}
+	}
+
+	r.ID, err = MachineID()
+	if err != nil {
+		// the host name still keeps us in (or out of) the rollout from run to run
+		log.Printf("error getting machine id from %s: %s. Will use the host name for the rollout instead", MachineIDFile, err)
+		r.ID, err = os.Hostname()
+		if err != nil {
+			return nil, fmt.Errorf("error identifying this machine for the staged rollout: %w", err)
+		}
+	}
+
+	return &r, nil
+}
+
 func ForLocalUse(debug bool) (*State, error) {
 	logger.SetUp(debug)
 	s := State{}
@@ -0,0 +1,95 @@
+package version
+
+import (
+	"fmt"
+	"regexp"
+	"runtime"
+	"strings"
+
+	semver "github.com/hashicorp/go-version"
+)
+
+// DefaultAssetPattern is the release asset name pattern our release workflow produces,
+// like go-cli-selfupdate-v0.4.0-linux-amd64.tar.gz (see Policy.AssetPattern)
+const DefaultAssetPattern = `{name}-{version}-{os}-{arch}\.{ext}`
+
+// archAliases are the names an architecture may have in release asset names, besides
+// its GOARCH name
+var archAliases = map[string][]string{
+	"amd64": {"x86_64", "x64"},
+	"386":   {"i386", "i686", "x86"},
+	"arm64": {"aarch64"},
+	"arm":   {"armv7", "armv6"},
+}
+
+// assetExts are the archive formats we know how to uncompress (see
+// selfupdate.Uncompress)
+var assetExts = []string{`tar\.gz`, `tgz`, `zip`}
+
+// assetRegexp compiles the release asset name pattern (see Policy.AssetPattern) into a
+// regular expression matching the asset of version v for the platform we are running
+// on. If v is nil, any version matches.
+func assetRegexp(pattern string, v *semver.Version) (*regexp.Regexp, error) {
+	if pattern == "" {
+		pattern = DefaultAssetPattern
+	}
+
+	version := `.+`
+	if v != nil {
+		orig := strings.TrimPrefix(v.Original(), "v")
+		version = fmt.Sprintf(`v?(?:%s|%s)`, regexp.QuoteMeta(orig), regexp.QuoteMeta(v.String()))
+	}
+
+	arch := []string{runtime.GOARCH}
+	for _, alias := range archAliases[runtime.GOARCH] {
+		arch = append(arch, regexp.QuoteMeta(alias))
+	}
+
+	expr := strings.NewReplacer(
+		"{name}", `.+`,
+		"{version}", version,
+		"{os}", regexp.QuoteMeta(runtime.GOOS),
+		"{arch}", "(?:"+strings.Join(arch, "|")+")",
+		"{ext}", "(?:"+strings.Join(assetExts, "|")+")",
+	).Replace(pattern)
+
+	re, err := regexp.Compile("(?i)^(?:" + expr + ")$")
+	if err != nil {
+		return nil, fmt.Errorf("invalid release asset pattern %q: %w", pattern, err)
+	}
+
+	return re, nil
+}
+
+// platformAsset returns the index of the release asset, among names, of version v (if
+// known) for the platform we are running on.
+//
+// It fails if no asset, or more than one, matches the asset pattern.
+func (s *versionSet) platformAsset(names []string, v *semver.Version) (int, error) {
+	re, err := assetRegexp(s.assetPattern, v)
+	if err != nil {
+		return -1, err
+	}
+
+	found := []int{}
+	for i, name := range names {
+		if re.MatchString(name) {
+			found = append(found, i)
+		}
+	}
+
+	switch len(found) {
+	case 0:
+		return -1, fmt.Errorf("no release asset for %s/%s matches %s among [%s]",
+			runtime.GOOS, runtime.GOARCH, re, strings.Join(names, ", "))
+	case 1:
+		return found[0], nil
+	}
+
+	matched := make([]string, len(found))
+	for i, f := range found {
+		matched[i] = names[f]
+	}
+	return -1, fmt.Errorf("ambiguous release assets for %s/%s, all of %s match %s",
+		runtime.GOOS, runtime.GOARCH, strings.Join(matched, ", "), re)
+}
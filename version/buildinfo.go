@@ -0,0 +1,109 @@
+package version
+
+import (
+	"runtime"
+	"runtime/debug"
+	"strings"
+)
+
+// BuildTime is when this binary was built, if the build sets it, like with:
+//
+//	go build -ldflags "-X github.com/dgmorales/go-cli-selfupdate/version.BuildTime=$(date -u +%Y-%m-%dT%H:%M:%SZ)"
+//
+// Go itself only records when the built commit was made (see BuildInfo.CommitTime).
+var BuildTime string
+
+// interestingDeps are the module dependencies whose versions we report, by path prefix:
+// the ones deciding how we talk to our servers and release sources
+var interestingDeps = []string{
+	"k8s.io/client-go",
+	"github.com/google/go-github/",
+}
+
+// BuildInfo tells where this binary comes from
+type BuildInfo struct {
+	Version string `json:"version"`
+
+	// Commit is the VCS revision the binary was built from, and Dirty tells if there
+	// were uncommitted changes. Both are blank (or false) when built outside of a VCS
+	// checkout, or with -buildvcs=false.
+	Commit     string `json:"commit,omitempty"`
+	Dirty      bool   `json:"dirty"`
+	CommitTime string `json:"commitTime,omitempty"`
+
+	BuildTime string `json:"buildTime,omitempty"`
+	GoVersion string `json:"goVersion"`
+	Platform  string `json:"platform"`
+
+	Deps []Dependency `json:"deps,omitempty"`
+}
+
+// Dependency is a module this binary was built with
+type Dependency struct {
+	Path    string `json:"path"`
+	Version string `json:"version"`
+}
+
+// ReadBuildInfo tells where this binary comes from, as far as it was recorded in the
+// binary
+func ReadBuildInfo() BuildInfo {
+	bi, _ := debug.ReadBuildInfo()
+	return buildInfo(bi)
+}
+
+// buildInfo returns the BuildInfo from bi, which may be nil if the binary has none
+func buildInfo(bi *debug.BuildInfo) BuildInfo {
+	info := BuildInfo{
+		Version:   strings.TrimSpace(Current),
+		BuildTime: BuildTime,
+		GoVersion: runtime.Version(),
+		Platform:  runtime.GOOS + "/" + runtime.GOARCH,
+	}
+	if bi == nil {
+		return info
+	}
+
+	if bi.GoVersion != "" {
+		info.GoVersion = bi.GoVersion
+	}
+
+	for _, s := range bi.Settings {
+		switch s.Key {
+		case "vcs.revision":
+			info.Commit = s.Value
+		case "vcs.modified":
+			info.Dirty = s.Value == "true"
+		case "vcs.time":
+			info.CommitTime = s.Value
+		}
+	}
+
+	for _, m := range bi.Deps {
+		if !isInterestingDep(m.Path) {
+			continue
+		}
+		dep := Dependency{Path: m.Path, Version: m.Version}
+		// report what was actually built in: the replacement version, or its path if it
+		// is a local directory
+		if r := m.Replace; r != nil {
+			dep.Version = r.Version
+			if r.Version == "" {
+				dep.Version = r.Path
+			}
+		}
+		info.Deps = append(info.Deps, dep)
+	}
+
+	return info
+}
+
+// isInterestingDep tells if we report the version of the module at path
+func isInterestingDep(path string) bool {
+	for _, prefix := range interestingDeps {
+		if strings.HasPrefix(path, prefix) {
+			return true
+		}
+	}
+
+	return false
+}
@@ -0,0 +1,58 @@
+package version_test
+
+import (
+	"reflect"
+	"runtime/debug"
+	"strings"
+	"testing"
+
+	"github.com/dgmorales/go-cli-selfupdate/version"
+)
+
+func TestBuildInfo(t *testing.T) {
+	bi := &debug.BuildInfo{
+		GoVersion: "go1.18.9",
+		Deps: []*debug.Module{
+			{Path: "github.com/google/go-github/v48", Version: "v48.1.0"},
+			{Path: "github.com/spf13/cobra", Version: "v1.6.1"},
+			{Path: "k8s.io/client-go", Version: "v0.25.4", Replace: &debug.Module{Path: "../client-go"}},
+		},
+		Settings: []debug.BuildSetting{
+			{Key: "vcs", Value: "git"},
+			{Key: "vcs.revision", Value: "4f6a2c1e9d0b"},
+			{Key: "vcs.time", Value: "2022-12-01T10:00:00Z"},
+			{Key: "vcs.modified", Value: "true"},
+		},
+	}
+
+	info := version.BuildInfoFrom(bi)
+
+	if info.Version != strings.TrimSpace(version.Current) {
+		t.Errorf("expected version %s, got %s", version.Current, info.Version)
+	}
+	if info.GoVersion != "go1.18.9" {
+		t.Errorf("expected go version go1.18.9, got %s", info.GoVersion)
+	}
+	if info.Commit != "4f6a2c1e9d0b" || !info.Dirty || info.CommitTime != "2022-12-01T10:00:00Z" {
+		t.Errorf("expected dirty commit 4f6a2c1e9d0b from 2022-12-01T10:00:00Z, got %+v", info)
+	}
+
+	expDeps := []version.Dependency{
+		{Path: "github.com/google/go-github/v48", Version: "v48.1.0"},
+		{Path: "k8s.io/client-go", Version: "../client-go"},
+	}
+	if !reflect.DeepEqual(info.Deps, expDeps) {
+		t.Errorf("expected deps %+v, got %+v", expDeps, info.Deps)
+	}
+}
+
+func TestBuildInfoWithoutBuildInfo(t *testing.T) {
+	info := version.BuildInfoFrom(nil)
+
+	if info.GoVersion == "" || info.Platform == "" {
+		t.Errorf("expected go version and platform from the runtime, got %+v", info)
+	}
+	if info.Commit != "" || info.Dirty || info.Deps != nil {
+		t.Errorf("expected no vcs or deps info, got %+v", info)
+	}
+}
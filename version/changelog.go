@@ -0,0 +1,66 @@
+package version
+
+import (
+	"context"
+	"sort"
+
+	semver "github.com/hashicorp/go-version"
+)
+
+// ReleaseNotes are the notes of a release, as published with it
+type ReleaseNotes struct {
+	Version string
+	Name    string
+	Body    string
+	URL     string
+
+	// RaisesMinimal means this release is the minimal required version: it is the
+	// release users must update to (at least), the one that bumped the minimal
+	// required version
+	RaisesMinimal bool
+}
+
+// Changelogger is implemented by Checkers that can tell the release notes of the
+// releases between the current and the latest versions
+type Changelogger interface {
+	// Changelog returns the notes of the releases above the current version, up to
+	// (and including) the latest one, newest first
+	Changelog(ctx context.Context) ([]ReleaseNotes, error)
+}
+
+// releaseNote is a ReleaseNotes along with its parsed version, for sorting
+type releaseNote struct {
+	ReleaseNotes
+	v *semver.Version
+}
+
+// inChangelog tells if a release of version v belongs in the changelog: if it is above
+// the current version, up to the latest
+func (s *versionSet) inChangelog(v *semver.Version) bool {
+	return s.current != nil && s.latest != nil && v.GreaterThan(s.current) && !v.GreaterThan(s.latest)
+}
+
+// changelog sorts notes newest first, marking the release that raised the minimal
+// required version above the current one, if any: the oldest release at or above the
+// minimal required version
+func (s *versionSet) changelog(notes []releaseNote) []ReleaseNotes {
+	sort.Slice(notes, func(i, j int) bool {
+		return notes[i].v.GreaterThan(notes[j].v)
+	})
+
+	if s.minimal != nil && s.current != nil && s.current.LessThan(s.minimal) {
+		for i := len(notes) - 1; i >= 0; i-- {
+			if !notes[i].v.LessThan(s.minimal) {
+				notes[i].RaisesMinimal = true
+				break
+			}
+		}
+	}
+
+	out := make([]ReleaseNotes, len(notes))
+	for i, n := range notes {
+		out[i] = n.ReleaseNotes
+	}
+
+	return out
+}
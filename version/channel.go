@@ -0,0 +1,55 @@
+package version
+
+import (
+	"fmt"
+	"strings"
+
+	semver "github.com/hashicorp/go-version"
+)
+
+// Channel is a release channel, choosing which releases are considered when looking
+// for the latest version.
+//
+// Channels are nested: stable releases are part of all channels, and beta releases are
+// part of the nightly channel too.
+type Channel string
+
+const (
+	// ChannelStable has only stable releases (no prereleases)
+	ChannelStable Channel = "stable"
+
+	// ChannelBeta has stable releases and prereleases (like v1.2.0-rc.1), except nightly
+	// builds
+	ChannelBeta Channel = "beta"
+
+	// ChannelNightly has all releases, including nightly builds (prereleases tagged like
+	// v1.2.0-nightly.20221201)
+	ChannelNightly Channel = "nightly"
+)
+
+// nightlyPrerelease is the prerelease prefix of nightly builds
+const nightlyPrerelease = "nightly"
+
+// ParseChannel parses a channel name. Blank means the stable channel.
+func ParseChannel(s string) (Channel, error) {
+	switch ch := Channel(strings.ToLower(strings.TrimSpace(s))); ch {
+	case "":
+		return ChannelStable, nil
+	case ChannelStable, ChannelBeta, ChannelNightly:
+		return ch, nil
+	}
+
+	return "", fmt.Errorf("unknown release channel %q (expected %s, %s or %s)", s, ChannelStable, ChannelBeta, ChannelNightly)
+}
+
+// includes tells if version v is released in the channel
+func (ch Channel) includes(v *semver.Version) bool {
+	switch ch {
+	case ChannelNightly:
+		return true
+	case ChannelBeta:
+		return !strings.HasPrefix(v.Prerelease(), nightlyPrerelease)
+	}
+
+	return v.Prerelease() == ""
+}
@@ -0,0 +1,123 @@
+package version
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/hex"
+	"fmt"
+	"strings"
+)
+
+// checksumNames are the (lower case) names of release assets we take as checksum
+// manifests. Names ending in checksums.txt, like goreleaser's
+// <name>_<version>_checksums.txt, are taken as checksum manifests too.
+var checksumNames = []string{"sha256sums", "sha256sums.txt", "checksums.txt"}
+
+// isChecksumManifest tells if the release asset name is a checksum manifest
+func isChecksumManifest(name string) bool {
+	name = strings.ToLower(name)
+	for _, n := range checksumNames {
+		if name == n {
+			return true
+		}
+	}
+
+	return strings.HasSuffix(name, "checksums.txt")
+}
+
+// checksumManifest returns the index of the checksum manifest among release asset
+// names, or -1 if there is none
+func checksumManifest(names []string) int {
+	for i, name := range names {
+		if isChecksumManifest(name) {
+			return i
+		}
+	}
+
+	return -1
+}
+
+// parseChecksums parses a checksum manifest as written by sha256sum (GNU style, with
+// "<digest>  <name>" lines) or by shasum --tag (BSD style, with "SHA256 (<name>) =
+// <digest>" lines), returning hex digests by file name.
+func parseChecksums(data []byte) (map[string]string, error) {
+	sums := map[string]string{}
+
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	for n := 1; scanner.Scan(); n++ {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+
+		var name, digest string
+		if rest, ok := cutPrefix(line, "SHA256 ("); ok {
+			var found bool
+			name, digest, found = strings.Cut(rest, ") = ")
+			if !found {
+				return nil, fmt.Errorf("invalid line %d in checksum manifest", n)
+			}
+		} else {
+			fields := strings.Fields(line)
+			if len(fields) != 2 {
+				return nil, fmt.Errorf("invalid line %d in checksum manifest", n)
+			}
+			// a * before the name means binary mode, which means nothing to us
+			digest, name = fields[0], strings.TrimPrefix(fields[1], "*")
+		}
+
+		if _, err := hex.DecodeString(digest); err != nil || len(digest) != 64 {
+			return nil, fmt.Errorf("invalid sha256 digest in line %d of checksum manifest", n)
+		}
+		sums[name] = strings.ToLower(digest)
+	}
+
+	return sums, scanner.Err()
+}
+
+// cutPrefix is strings.CutPrefix, missing from our go version
+func cutPrefix(s string, prefix string) (string, bool) {
+	if !strings.HasPrefix(s, prefix) {
+		return s, false
+	}
+	return s[len(prefix):], true
+}
+
+// binaryName returns the name the binary in the release archive name is published with in
+// checksum manifests, if it is: the archive name without its extension. For patches (see
+// patchName), it is the name of the binary they patch to.
+func binaryName(archive string) string {
+	lower := strings.ToLower(archive)
+	if strings.HasSuffix(lower, patchExt) {
+		if i := strings.LastIndex(lower, patchInfix); i >= 0 {
+			return archive[:i]
+		}
+	}
+
+	for _, ext := range []string{".tar.gz", ".tgz", ".zip"} {
+		if strings.HasSuffix(lower, ext) {
+			return archive[:len(archive)-len(ext)]
+		}
+	}
+
+	return archive
+}
+
+// assetFromChecksums verifies the release archive name, downloaded to filename, against
+// the checksum manifest data, returning it as an Asset.
+//
+// The manifest must list the archive. It may also list the binary in it (see
+// binaryName).
+func assetFromChecksums(filename string, name string, data []byte) (*Asset, error) {
+	sums, err := parseChecksums(data)
+	if err != nil {
+		return nil, fmt.Errorf("in Download: %w", err)
+	}
+
+	sum, ok := sums[name]
+	if !ok {
+		return nil, fmt.Errorf("in Download: no checksum for %s in release checksum manifest", name)
+	}
+
+	return checkedAsset(filename, 0, sum, sums[binaryName(name)])
+}
@@ -0,0 +1,76 @@
+package version
+
+import (
+	"strings"
+
+	semver "github.com/hashicorp/go-version"
+)
+
+// violation returns the first clause of the policy version constraint the current
+// version violates, and what that means for it: MustUpdate if below the clause,
+// MustDowngrade if above it, or IsRevoked if excluded by it (with !=). It returns a nil
+// clause if there is no violation.
+func (s *versionSet) violation() (*semver.Constraint, Assertion) {
+	return s.violationOf(s.current)
+}
+
+// violationOf is like violation, for any version v
+func (s *versionSet) violationOf(v *semver.Version) (*semver.Constraint, Assertion) {
+	if v == nil {
+		return nil, IsUnknown
+	}
+
+	for _, c := range s.constraint {
+		if c.Check(v) {
+			continue
+		}
+
+		op, cv := splitClause(c.String())
+		switch op {
+		case "!=":
+			return c, IsRevoked
+		case ">", ">=":
+			return c, MustUpdate
+		case "<", "<=":
+			return c, MustDowngrade
+		}
+
+		// =, ~> (and no operator) bound both ways
+		if cv != nil && v.LessThan(cv) {
+			return c, MustUpdate
+		}
+		return c, MustDowngrade
+	}
+
+	return nil, IsUnknown
+}
+
+// splitClause splits a version constraint clause (like ">= 1.2.0") into its operator
+// and version (nil if it can't be parsed, which go-version would not let happen)
+func splitClause(clause string) (string, *semver.Version) {
+	clause = strings.TrimSpace(clause)
+	for _, op := range []string{"!=", ">=", "<=", "~>", ">", "<", "="} {
+		if rest, ok := cutPrefix(clause, op); ok {
+			v, _ := semver.NewSemver(strings.TrimSpace(rest))
+			return op, v
+		}
+	}
+
+	v, _ := semver.NewSemver(clause)
+	return "", v
+}
+
+// Violated returns the clause of the version constraint (from policy) the current
+// version violates, if any, like "!= 0.5.1"
+func (s *versionSet) Violated() string {
+	if s == nil {
+		return ""
+	}
+
+	clause, _ := s.violation()
+	if clause == nil {
+		return ""
+	}
+	// clauses keep the spaces around them in the constraint
+	return strings.TrimSpace(clause.String())
+}
@@ -0,0 +1,147 @@
+package version_test
+
+import (
+	"testing"
+
+	"github.com/dgmorales/go-cli-selfupdate/version"
+)
+
+func TestCheckEvaluatesVersionConstraint(t *testing.T) {
+	const constraint = ">= 0.4.0, != 0.5.1, < 2.0.0"
+
+	testCases := []struct {
+		desc        string
+		constraint  string
+		releases    []string // if nil, v0.9.0 and v1.0.0 (and latest is not checked)
+		cur         string
+		vAssert     version.Assertion
+		expViolated string
+		expLatest   string
+	}{
+		{
+			desc:       "CanUpdateWithinConstraint",
+			constraint: constraint,
+			cur:        "v0.6.0",
+			vAssert:    version.CanUpdate,
+		},
+		{
+			desc:        "MustUpdateBelowLowerBound",
+			constraint:  constraint,
+			cur:         "v0.3.0",
+			vAssert:     version.MustUpdate,
+			expViolated: ">= 0.4.0",
+		},
+		{
+			desc:        "IsRevokedWhenExcluded",
+			constraint:  constraint,
+			cur:         "v0.5.1",
+			vAssert:     version.IsRevoked,
+			expViolated: "!= 0.5.1",
+		},
+		{
+			desc:        "MustDowngradeAboveUpperBound",
+			constraint:  constraint,
+			cur:         "v2.1.0",
+			vAssert:     version.MustDowngrade,
+			expViolated: "< 2.0.0",
+		},
+		{
+			desc:        "MustUpdateBelowPessimisticClause",
+			constraint:  "~> 0.6",
+			cur:         "v0.5.0",
+			vAssert:     version.MustUpdate,
+			expViolated: "~> 0.6",
+		},
+		{
+			desc:        "MustDowngradeAbovePessimisticClause",
+			constraint:  "~> 0.6",
+			cur:         "v1.5.0",
+			vAssert:     version.MustDowngrade,
+			expViolated: "~> 0.6",
+		},
+		{
+			desc:        "MustDowngradeAtLatestReleaseAboveUpperBound",
+			constraint:  constraint,
+			releases:    []string{"v0.9.0", "v2.1.0"},
+			cur:         "v2.1.0",
+			vAssert:     version.MustDowngrade,
+			expViolated: "< 2.0.0",
+			expLatest:   "0.9.0",
+		},
+		{
+			desc:        "MustDowngradeToReleaseWithinConstraint",
+			constraint:  constraint,
+			releases:    []string{"v0.9.0", "v2.1.0"},
+			cur:         "v2.2.0",
+			vAssert:     version.MustDowngrade,
+			expViolated: "< 2.0.0",
+			expLatest:   "0.9.0",
+		},
+		{
+			desc:       "IsLatestAtNewestReleaseWithinConstraint",
+			constraint: constraint,
+			releases:   []string{"v0.9.0", "v2.1.0"},
+			cur:        "v0.9.0",
+			vAssert:    version.IsLatest,
+			expLatest:  "0.9.0",
+		},
+		{
+			desc:       "LatestReleaseAboveUpperBoundIsNotOffered",
+			constraint: constraint,
+			releases:   []string{"v0.5.1", "v0.9.0", "v2.1.0"},
+			cur:        "v0.6.0",
+			vAssert:    version.CanUpdate,
+			expLatest:  "0.9.0",
+		},
+		{
+			desc:       "ExcludedLatestReleaseIsNotOffered",
+			constraint: constraint,
+			releases:   []string{"v0.4.0", "v0.5.1"},
+			cur:        "v0.4.0",
+			vAssert:    version.IsLatest,
+			expLatest:  "0.4.0",
+		},
+		{
+			desc:       "IsUnknownWhenNoReleaseIsWithinConstraint",
+			constraint: constraint,
+			releases:   []string{"v2.1.0"},
+			cur:        "v0.6.0",
+			vAssert:    version.IsUnknown,
+		},
+	}
+	for _, tC := range testCases {
+		t.Run(tC.desc, func(t *testing.T) {
+			releases := tC.releases
+			if releases == nil {
+				releases = []string{"v0.9.0", "v1.0.0"}
+			}
+			dir := newReleaseDir(t, "", releases...)
+
+			ldc, err := version.NewLocalDirChecker(dir, version.Policy{Constraint: tC.constraint}, tC.cur)
+			if err != nil {
+				t.Fatalf("expected nil error, got %s", err)
+			}
+
+			if tC.releases != nil && ldc.Latest() != tC.expLatest {
+				t.Errorf("expected latest version %q, got %q", tC.expLatest, ldc.Latest())
+			}
+
+			if ans := ldc.Check(); ans != tC.vAssert {
+				t.Errorf("expected '%d/%s', got '%d/%s'", tC.vAssert, assertStr(tC.vAssert), ans, assertStr(ans))
+			}
+			if ldc.Violated() != tC.expViolated {
+				t.Errorf("expected violated clause %q, got %q", tC.expViolated, ldc.Violated())
+			}
+			if r := version.Snapshot(ldc); r.Violated != tC.expViolated {
+				t.Errorf("expected result with violated clause %q, got %q", tC.expViolated, r.Violated)
+			}
+		})
+	}
+}
+
+func TestNewCheckerFailsWithInvalidVersionConstraint(t *testing.T) {
+	_, err := version.NewLocalDirChecker(newReleaseDir(t, "", "v1.0.0"), version.Policy{Constraint: ">= 0.4.0, !! 0.5"}, "v0.6.0")
+	if err == nil {
+		t.Error("expected error for invalid version constraint, got nil")
+	}
+}
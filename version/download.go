@@ -0,0 +1,485 @@
+package version
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Progress is called as downloads progress, with the name of the file being downloaded,
+// how many bytes of it we have so far, and its total size (-1 if unknown)
+type Progress func(name string, done int64, total int64)
+
+// maxResumes is how many times we resume an interrupted download before giving up
+const maxResumes = 5
+
+// resumeDelay is how long we wait before resuming an interrupted download
+var resumeDelay = time.Second
+
+// partExt is the extension of partial downloads. They are kept on errors (in
+// DownloadDir), for resuming them on the next try (even by the next run).
+const partExt = ".part"
+
+// opener starts a download from offset on, asking for a range of the file if offset is
+// not zero. Servers may ignore the range, answering with the whole file.
+type opener func(offset int64) (*http.Response, error)
+
+// downloader downloads release files, resuming interrupted downloads and reporting their
+// progress. Checker implementations embed it.
+type downloader struct {
+	progress Progress
+}
+
+// SetProgress makes downloads report their progress to p
+func (d *downloader) SetProgress(p Progress) {
+	d.progress = p
+}
+
+// DownloadDir is where releases are downloaded to, and partial downloads kept for
+// resuming them. Only the current user may access it: otherwise, another local user
+// could plant a partial download for us to resume.
+var DownloadDir = downloadDir()
+
+// downloadDir returns the downloads dir in the XDG cache dir, falling back to a dir of
+// the current user in the temp dir if we can't tell where the user cache is
+func downloadDir() string {
+	cacheDir, err := os.UserCacheDir()
+	if err != nil {
+		return filepath.Join(os.TempDir(), fmt.Sprintf("go-cli-selfupdate-%d", os.Getuid()))
+	}
+
+	return filepath.Join(cacheDir, "go-cli-selfupdate", "downloads")
+}
+
+// downloadPath returns the file path for downloading name, creating DownloadDir if
+// needed. It refuses to use a DownloadDir the current user does not own.
+func downloadPath(name string) (string, error) {
+	err := os.MkdirAll(DownloadDir, 0o700)
+	if err != nil {
+		return "", fmt.Errorf("error creating release download dir: %w", err)
+	}
+
+	info, err := os.Lstat(DownloadDir)
+	if err != nil {
+		return "", fmt.Errorf("error creating release download dir: %w", err)
+	}
+	if !info.IsDir() || !ownedByUs(info) {
+		return "", fmt.Errorf("refusing to download releases to %s: not a dir owned by the current user", DownloadDir)
+	}
+	if info.Mode().Perm()&0o077 != 0 {
+		err = os.Chmod(DownloadDir, 0o700)
+		if err != nil {
+			return "", fmt.Errorf("error making release download dir private: %w", err)
+		}
+	}
+
+	return filepath.Join(DownloadDir, name), nil
+}
+
+func openFileForDownload(name string) (filename string, fd *os.File, err error) {
+	filename, err = downloadPath(name)
+	if err != nil {
+		return filename, nil, err
+	}
+
+	fd, err = os.OpenFile(filename, os.O_CREATE|os.O_TRUNC|os.O_WRONLY, 0o600)
+	if err != nil {
+		return filename, nil, fmt.Errorf("error creating file for release download: %w", err)
+	}
+
+	return filename, fd, nil
+}
+
+// openPart opens the partial download of filename for resuming it, creating it if there
+// is none. It refuses to resume one the current user does not own.
+func openPart(filename string) (*os.File, error) {
+	f, err := os.OpenFile(filename+partExt, os.O_CREATE|os.O_WRONLY, 0o600)
+	if err != nil {
+		return nil, fmt.Errorf("error creating file for release download: %w", err)
+	}
+
+	info, err := f.Stat()
+	if err != nil {
+		f.Close()
+		return nil, fmt.Errorf("error creating file for release download: %w", err)
+	}
+	if !info.Mode().IsRegular() || !ownedByUs(info) {
+		f.Close()
+		return nil, fmt.Errorf("refusing to resume partial download %s: not a file owned by the current user", f.Name())
+	}
+	if info.Mode().Perm()&0o077 != 0 {
+		err = f.Chmod(0o600)
+		if err != nil {
+			f.Close()
+			return nil, fmt.Errorf("error making partial download private: %w", err)
+		}
+	}
+
+	return f, nil
+}
+
+// urlOpener opens downloads of url with plain HTTP GETs, canceled with ctx. If client is
+// nil, http.DefaultClient is used.
+func urlOpener(ctx context.Context, client *http.Client, url string) opener {
+	if client == nil {
+		client = http.DefaultClient
+	}
+
+	return func(offset int64) (*http.Response, error) {
+		req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+		if err != nil {
+			return nil, err
+		}
+		if offset > 0 {
+			req.Header.Set("Range", fmt.Sprintf("bytes=%d-", offset))
+		}
+
+		return client.Do(req)
+	}
+}
+
+// downloadURL downloads the asset at url to a temporary file named after name
+//
+// It is a helper for Checker implementations whose assets are reachable with a plain
+// HTTP GET.
+func (d *downloader) downloadURL(ctx context.Context, client *http.Client, url string, name string) (filename string, err error) {
+	return d.fetch(ctx, urlOpener(ctx, client, url), name)
+}
+
+// fetch downloads the file name with open to a temporary file named after it, resuming
+// the download if it is interrupted (or if a previous one was).
+//
+// It gives up when ctx is done, keeping the partial download for the next try.
+func (d *downloader) fetch(ctx context.Context, open opener, name string) (filename string, err error) {
+	filename, err = downloadPath(name)
+	if err != nil {
+		return filename, err
+	}
+
+	f, err := openPart(filename)
+	if err != nil {
+		return filename, err
+	}
+	defer f.Close()
+
+	for resumes := 0; ; resumes++ {
+		var retry bool
+		retry, err = d.fetchRest(open, f, name)
+		if err == nil {
+			break
+		}
+		if ctx.Err() != nil {
+			return filename, fmt.Errorf("in Download: %w", ctx.Err())
+		}
+		if !retry || resumes == maxResumes {
+			return filename, fmt.Errorf("in Download: %w", err)
+		}
+
+		log.Printf("download of %s interrupted: %s. Resuming it", name, err)
+		select {
+		case <-ctx.Done():
+			return filename, fmt.Errorf("in Download: %w", ctx.Err())
+		case <-time.After(resumeDelay):
+		}
+	}
+
+	err = f.Close()
+	if err != nil {
+		return filename, fmt.Errorf("in Download: %w", err)
+	}
+
+	err = os.Rename(f.Name(), filename)
+	if err != nil {
+		return filename, fmt.Errorf("in Download: %w", err)
+	}
+
+	return filename, nil
+}
+
+// fetchRest downloads the rest of the partial download f (of the file name) with open,
+// telling if it is worth retrying on errors
+func (d *downloader) fetchRest(open opener, f *os.File, name string) (retry bool, err error) {
+	offset, err := f.Seek(0, io.SeekEnd)
+	if err != nil {
+		return false, err
+	}
+
+	resp, err := open(offset)
+	if err != nil {
+		var netErr net.Error
+		return errors.As(err, &netErr), err
+	}
+	defer resp.Body.Close()
+
+	total := int64(-1)
+	switch resp.StatusCode {
+	case http.StatusOK:
+		// a new download, or a server ignoring our range
+		offset = 0
+		err = truncate(f)
+		if err != nil {
+			return false, err
+		}
+		if resp.ContentLength >= 0 {
+			total = resp.ContentLength
+		}
+
+	case http.StatusPartialContent:
+		var start int64
+		start, total, err = parseContentRange(resp.Header.Get("Content-Range"))
+		if err != nil || start != offset {
+			return true, truncateFor(f, fmt.Errorf("got range %q resuming download at %d, starting over",
+				resp.Header.Get("Content-Range"), offset))
+		}
+
+	case http.StatusRequestedRangeNotSatisfiable:
+		// the file must have changed since the partial download
+		return true, truncateFor(f, errors.New("partial download does not match the file, starting over"))
+
+	default:
+		// server errors may be temporary, unlike anything else
+		return resp.StatusCode >= 500, fmt.Errorf("unexpected status downloading %s: %s", name, resp.Status)
+	}
+
+	w := progressWriter{w: f, name: name, done: offset, total: total, progress: d.progress}
+	w.report()
+
+	_, err = io.Copy(&w, resp.Body)
+	if err != nil {
+		return true, err
+	}
+
+	if w.total < 0 {
+		// let progress know we are done
+		w.total = w.done
+		w.report()
+	}
+
+	return false, nil
+}
+
+// truncate empties the partial download f, to start it over
+func truncate(f *os.File) error {
+	err := f.Truncate(0)
+	if err != nil {
+		return err
+	}
+
+	_, err = f.Seek(0, io.SeekStart)
+	return err
+}
+
+// truncateFor empties the partial download f to start it over because of err, returning
+// err (or the error truncating, if any)
+func truncateFor(f *os.File, err error) error {
+	if truncErr := truncate(f); truncErr != nil {
+		return truncErr
+	}
+
+	return err
+}
+
+// parseContentRange parses a Content-Range header like "bytes 200-999/1000", returning
+// the range start and the total size (-1 if unknown)
+func parseContentRange(s string) (start int64, total int64, err error) {
+	rng, size, ok := strings.Cut(strings.TrimPrefix(s, "bytes "), "/")
+	first, _, ok2 := strings.Cut(rng, "-")
+	if !ok || !ok2 {
+		return 0, 0, fmt.Errorf("invalid content range %q", s)
+	}
+
+	start, err = strconv.ParseInt(first, 10, 64)
+	if err != nil {
+		return 0, 0, fmt.Errorf("invalid content range %q", s)
+	}
+
+	if size == "*" {
+		return start, -1, nil
+	}
+	total, err = strconv.ParseInt(size, 10, 64)
+	if err != nil {
+		return 0, 0, fmt.Errorf("invalid content range %q", s)
+	}
+
+	return start, total, nil
+}
+
+// progressWriter reports the progress of writes to a download
+type progressWriter struct {
+	w        io.Writer
+	name     string
+	done     int64
+	total    int64
+	progress Progress
+}
+
+func (p *progressWriter) Write(b []byte) (int, error) {
+	n, err := p.w.Write(b)
+	p.done += int64(n)
+	p.report()
+
+	return n, err
+}
+
+// report calls progress, if any
+func (p *progressWriter) report() {
+	if p.progress != nil {
+		p.progress(p.name, p.done, p.total)
+	}
+}
+
+// contextReader reads from r until ctx is done
+type contextReader struct {
+	ctx context.Context
+	r   io.Reader
+}
+
+func (c contextReader) Read(b []byte) (int, error) {
+	if err := c.ctx.Err(); err != nil {
+		return 0, err
+	}
+
+	return c.r.Read(b)
+}
+
+// urlAsset is a release asset reachable with a plain HTTP GET, along with the release
+// checksum manifest, the binary signature and the patch from the current version, if any
+type urlAsset struct {
+	name         string
+	url          string
+	checksumsURL string
+	signatureURL string
+	patchName    string
+	patchURL     string
+}
+
+// patch returns the patch from the current version as a release asset on its own
+func (a urlAsset) patch() urlAsset {
+	return urlAsset{name: a.patchName, url: a.patchURL, checksumsURL: a.checksumsURL, signatureURL: a.signatureURL}
+}
+
+// downloadURLAsset downloads asset (and its signature, if any) to temporary files,
+// verifying it against the release checksum manifest, if any
+func (d *downloader) downloadURLAsset(ctx context.Context, client *http.Client, asset urlAsset) (*Asset, error) {
+	filename, err := d.downloadURL(ctx, client, asset.url, asset.name)
+	if err != nil {
+		return nil, err
+	}
+
+	var a *Asset
+	if asset.checksumsURL == "" {
+		log.Printf("no checksum manifest in release, %s will not be verified", asset.name)
+		a, err = checkedAsset(filename, 0, "", "")
+	} else {
+		var sums []byte
+		sums, err = readURL(ctx, client, asset.checksumsURL)
+		if err != nil {
+			return nil, fmt.Errorf("in Download: error getting release checksum manifest: %w", err)
+		}
+		a, err = assetFromChecksums(filename, asset.name, sums)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	if asset.signatureURL != "" {
+		a.Signature, err = d.downloadURL(ctx, client, asset.signatureURL, signatureName(asset.name))
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return a, nil
+}
+
+// readURL reads the (small) file at url, like a checksum manifest
+func readURL(ctx context.Context, client *http.Client, url string) ([]byte, error) {
+	if client == nil {
+		client = http.DefaultClient
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("unexpected status getting %s: %s", url, resp.Status)
+	}
+
+	return io.ReadAll(resp.Body)
+}
+
+// checkedAsset verifies the file downloaded to filename against the size and
+// hex SHA-256 checksums published for it (see verifyDownload), returning it as an Asset.
+// Zero size and blank checksums mean there are none.
+func checkedAsset(filename string, size int64, sha256Hex string, binarySHA256Hex string) (*Asset, error) {
+	var err error
+	a := Asset{Filename: filename}
+
+	err = verifyDownload(filename, size, sha256Hex)
+	if err != nil {
+		return nil, fmt.Errorf("in Download: %w", err)
+	}
+
+	for _, sum := range []struct {
+		dst *[]byte
+		hex string
+	}{
+		{&a.SHA256, sha256Hex},
+		{&a.BinarySHA256, binarySHA256Hex},
+	} {
+		if sum.hex == "" {
+			continue
+		}
+		*sum.dst, err = hex.DecodeString(sum.hex)
+		if err != nil {
+			return nil, fmt.Errorf("in Download: invalid sha256 checksum %q: %w", sum.hex, err)
+		}
+	}
+
+	return &a, nil
+}
+
+// verifyDownload checks a downloaded file against the size and SHA-256 hex digest
+// published for it. Zero size and blank digest are not checked.
+func verifyDownload(filename string, size int64, sha256Hex string) error {
+	f, err := os.Open(filename)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	h := sha256.New()
+	n, err := io.Copy(h, f)
+	if err != nil {
+		return err
+	}
+
+	if size != 0 && n != size {
+		return fmt.Errorf("downloaded file %s has %d bytes, expected %d", filename, n, size)
+	}
+
+	if sha256Hex != "" && !strings.EqualFold(hex.EncodeToString(h.Sum(nil)), sha256Hex) {
+		return fmt.Errorf("downloaded file %s has wrong sha256 checksum (expected %s)", filename, sha256Hex)
+	}
+
+	return nil
+}
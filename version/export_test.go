@@ -0,0 +1,26 @@
+package version
+
+import (
+	"runtime/debug"
+	"time"
+)
+
+// SetResumeDelay makes interrupted downloads resume after d, returning the previous delay
+func SetResumeDelay(d time.Duration) time.Duration {
+	old := resumeDelay
+	resumeDelay = d
+	return old
+}
+
+// SetAPIRetryDelay makes failed GitHub API calls be retried after d (doubling on each
+// retry), returning the previous delay
+func SetAPIRetryDelay(d time.Duration) time.Duration {
+	old := apiRetryDelay
+	apiRetryDelay = d
+	return old
+}
+
+// BuildInfoFrom returns the BuildInfo from bi, as ReadBuildInfo does for our own
+func BuildInfoFrom(bi *debug.BuildInfo) BuildInfo {
+	return buildInfo(bi)
+}
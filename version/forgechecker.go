@@ -0,0 +1,300 @@
+package version
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+
+	semver "github.com/hashicorp/go-version"
+)
+
+// forgeRelease is a release published in a forge with a releases API (like Gitea or
+// GitLab), mapped from the forge's own API response
+type forgeRelease struct {
+	tag    string
+	url    string
+	assets []forgeAsset
+}
+
+// forgeAsset is a release asset reachable with a plain HTTP GET
+type forgeAsset struct {
+	name string
+	url  string
+}
+
+// forgeAPI gets releases from a forge releases API. Implementations only map the forge
+// API responses, leaving everything else to forgeChecker.
+type forgeAPI interface {
+	// latestRelease gets the latest release, ignoring drafts and prereleases
+	latestRelease(ctx context.Context) (*forgeRelease, error)
+
+	// releaseByTag gets the release tagged tag
+	releaseByTag(ctx context.Context, tag string) (*forgeRelease, error)
+
+	// releases lists the most recent releases, ignoring drafts
+	releases(ctx context.Context) ([]*forgeRelease, error)
+}
+
+// forgeChecker implements Checker for forges with a releases API whose assets are
+// reachable with a plain HTTP GET. Checker implementations for such forges embed it,
+// providing the forgeAPI.
+type forgeChecker struct {
+	versionSet
+	downloader
+	forge    string
+	api      forgeAPI
+	client   *http.Client
+	asset    urlAsset
+	assetErr error
+}
+
+// newForgeChecker discovers what is the latest version from api, the releases API of
+// forge (a name for messages, like "gitea") at source (like "repo owner/name"), saving
+// asset information for calling Download().
+//
+// If client is nil, http.DefaultClient is used for downloads.
+func newForgeChecker(ctx context.Context, forge string, source string, api forgeAPI, client *http.Client, policy Policy, current string) (forgeChecker, error) {
+	var err error
+	fc := forgeChecker{forge: forge, api: api}
+
+	fc.versionSet, err = newVersionSet(policy, current)
+	if err != nil {
+		return fc, err
+	}
+
+	if client == nil {
+		fc.client = http.DefaultClient
+	} else {
+		fc.client = client
+	}
+
+	latest, err := fc.getTargetRelease(ctx)
+	if err != nil {
+		// We won't error if we can't get the latest version.
+		// Instead we will proceed without the latest version (and assets) info
+		fc.latestErr = fmt.Errorf("error getting latest %s release from %s: %s", forge, source, err)
+		log.Printf("%s. Will ignore and continue with latest version as unknown", fc.latestErr)
+		return fc, nil
+	}
+
+	err = fc.setLatest(latest.tag)
+	if err != nil {
+		return fc, err
+	}
+
+	fc.asset, fc.assetErr = fc.releaseAsset(latest)
+	if fc.assetErr != nil {
+		log.Printf("error picking %s release asset: %s", forge, fc.assetErr)
+	}
+	fc.release = ReleaseInfo{Asset: fc.asset.name, URL: latest.url}
+
+	return fc, nil
+}
+
+// releaseAsset returns the release asset for the platform we are running on
+func (c *forgeChecker) releaseAsset(release *forgeRelease) (urlAsset, error) {
+	v, err := semver.NewSemver(release.tag)
+	if err != nil {
+		return urlAsset{}, err
+	}
+
+	names := make([]string, len(release.assets))
+	for i, asset := range release.assets {
+		names[i] = asset.name
+	}
+
+	i, err := c.platformAsset(names, v)
+	if err != nil {
+		return urlAsset{}, err
+	}
+
+	asset := urlAsset{name: names[i], url: release.assets[i].url}
+	if i := checksumManifest(names); i >= 0 {
+		asset.checksumsURL = release.assets[i].url
+	}
+	if i := signatureAsset(names, asset.name); i >= 0 {
+		asset.signatureURL = release.assets[i].url
+	}
+	if i := c.patchAsset(names, asset.name); i >= 0 {
+		asset.patchName, asset.patchURL = names[i], release.assets[i].url
+	}
+
+	return asset, nil
+}
+
+// getTargetRelease gets the release users should be running: the latest one, unless
+// policy pins or caps versions. If policy does not allow it (like when it is revoked),
+// the newest older release it allows is taken instead.
+func (c *forgeChecker) getTargetRelease(ctx context.Context) (*forgeRelease, error) {
+	if c.pinned != nil {
+		return c.getReleaseByVersion(ctx, c.pinned)
+	}
+
+	latest, err := c.api.latestRelease(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	latestV, err := semver.NewSemver(latest.tag)
+	if err != nil {
+		// let the caller complain about it
+		return latest, nil
+	}
+
+	if v := c.limit(latestV); v != nil {
+		latest, err = c.getReleaseByVersion(ctx, v)
+		if err != nil {
+			return nil, err
+		}
+		latestV = v
+	}
+
+	if !c.allowed(latestV) {
+		return c.getFallbackRelease(ctx, latestV)
+	}
+
+	return latest, nil
+}
+
+// getFallbackRelease gets the newest release below version v that policy allows, among
+// the most recent ones
+func (c *forgeChecker) getFallbackRelease(ctx context.Context, v *semver.Version) (*forgeRelease, error) {
+	releases, err := c.api.releases(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	versions := make([]*semver.Version, len(releases))
+	for i, r := range releases {
+		rv, err := semver.NewSemver(r.tag)
+		if err != nil || rv.Prerelease() != "" {
+			continue
+		}
+		versions[i] = rv
+	}
+
+	i := c.fallback(v, versions)
+	if i < 0 {
+		return nil, fmt.Errorf("%w (%s is not)", errNoAllowedRelease, v)
+	}
+
+	return releases[i], nil
+}
+
+// getReleaseByVersion gets the release of version v, looking it up by tag name
+func (c *forgeChecker) getReleaseByVersion(ctx context.Context, v *semver.Version) (release *forgeRelease, err error) {
+	for _, tag := range releaseTags(v) {
+		release, err = c.api.releaseByTag(ctx, tag)
+		if err == nil {
+			return release, nil
+		}
+	}
+
+	return nil, err
+}
+
+// DownloadLatest downloads the saved release asset to a temporary file, verifying it
+// against the release checksum manifest, if any
+func (c *forgeChecker) DownloadLatest(ctx context.Context) (*Asset, error) {
+	if c == nil {
+		return nil, fmt.Errorf("in forgeChecker.DownloadLatest: called with nil receiver")
+	}
+
+	if c.assetErr != nil {
+		return nil, fmt.Errorf("in Download: %w", c.assetErr)
+	}
+
+	if c.asset.name == "" || c.asset.url == "" {
+		return nil, fmt.Errorf("in Download: %s release asset information is unavailable", c.forge)
+	}
+
+	return c.downloadURLAsset(ctx, c.client, c.asset)
+}
+
+// DownloadVersion downloads the release asset of version v to a temporary file, looking
+// the release up by tag name. It is verified against the release checksum manifest, if
+// any.
+func (c *forgeChecker) DownloadVersion(ctx context.Context, v string) (*Asset, error) {
+	if c == nil {
+		return nil, fmt.Errorf("in forgeChecker.DownloadVersion: called with nil receiver")
+	}
+
+	asset, err := c.versionAsset(ctx, v)
+	if err != nil {
+		return nil, err
+	}
+
+	return c.downloadURLAsset(ctx, c.client, asset)
+}
+
+// DownloadPatch downloads the release asset with the patch from the current version to
+// version v to a temporary file. It is verified against the release checksum manifest,
+// that must list the patched binary too.
+func (c *forgeChecker) DownloadPatch(ctx context.Context, v string) (*Asset, error) {
+	if c == nil {
+		return nil, fmt.Errorf("in forgeChecker.DownloadPatch: called with nil receiver")
+	}
+
+	asset := c.asset
+	if !c.isLatest(v) || c.assetErr != nil {
+		var err error
+		asset, err = c.versionAsset(ctx, v)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	if asset.patchURL == "" || asset.checksumsURL == "" {
+		return nil, ErrNoPatch
+	}
+
+	return patched(c.downloadURLAsset(ctx, c.client, asset.patch()))
+}
+
+// versionAsset returns the release asset of version v, looking the release up by tag
+// name
+func (c *forgeChecker) versionAsset(ctx context.Context, v string) (urlAsset, error) {
+	want, err := semver.NewSemver(v)
+	if err != nil {
+		return urlAsset{}, fmt.Errorf("in Download: %w", err)
+	}
+
+	release, err := c.getReleaseByVersion(ctx, want)
+	if err != nil {
+		return urlAsset{}, fmt.Errorf("in Download: error getting %s release %s: %w", c.forge, v, err)
+	}
+
+	asset, err := c.releaseAsset(release)
+	if err != nil {
+		return urlAsset{}, fmt.Errorf("in Download: %s release %s: %w", c.forge, v, err)
+	}
+
+	return asset, nil
+}
+
+// getJSON gets the forge API url with client, decoding its JSON response into v
+func getJSON(ctx context.Context, client *http.Client, url string, v interface{}) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return err
+	}
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("unexpected status %s", resp.Status)
+	}
+
+	err = json.NewDecoder(resp.Body).Decode(v)
+	if err != nil {
+		return fmt.Errorf("error parsing release: %w", err)
+	}
+
+	return nil
+}
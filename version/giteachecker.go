@@ -0,0 +1,123 @@
+package version
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+)
+
+// GiteaChecker is a Checker for releases published as Gitea (or Forgejo) releases
+type GiteaChecker struct {
+	forgeChecker
+}
+
+// giteaRelease is the subset of the Gitea Releases API response we care about
+//
+// It looks a lot like GitHub's, but assets can only be downloaded from their browser
+// download URL (there is no octet-stream variant of the asset API).
+type giteaRelease struct {
+	TagName string `json:"tag_name"`
+	HTMLURL string `json:"html_url"`
+	Draft   bool   `json:"draft"`
+	Assets  []struct {
+		Name               string `json:"name"`
+		BrowserDownloadURL string `json:"browser_download_url"`
+	} `json:"assets"`
+}
+
+// forgeRelease maps r to a forgeRelease
+func (r *giteaRelease) forgeRelease() *forgeRelease {
+	fr := forgeRelease{tag: r.TagName, url: r.HTMLURL}
+	for _, a := range r.Assets {
+		fr.assets = append(fr.assets, forgeAsset{name: a.Name, url: a.BrowserDownloadURL})
+	}
+	return &fr
+}
+
+// giteaAPI is the forgeAPI of a Gitea repo releases
+type giteaAPI struct {
+	client  *http.Client
+	repoURL string
+}
+
+// NewGiteaChecker discovers what is the latest version from Gitea (or Forgejo) releases
+//
+// baseURL is the Gitea instance address (e.g. https://gitea.example.com).
+//
+// client should be authenticated for private repositories (see gitea.NewClient). If nil,
+// http.DefaultClient is used.
+//
+// If policy pins or caps versions, the pinned (or maximal allowed) release is taken as
+// latest instead. Releases policy does not allow (revoked, or outside the version
+// constraint) are never taken as latest: the newest allowed release below them is.
+//
+// It already saves asset information, leaving everything ready for calling Download().
+// Failing to get it in time (before ctx is done) leaves the latest version unknown.
+func NewGiteaChecker(ctx context.Context, client *http.Client, baseURL string, owner string, repo string, policy Policy, current string) (*GiteaChecker, error) {
+	var err error
+	gc := GiteaChecker{}
+
+	if baseURL == "" || owner == "" || repo == "" {
+		return nil, errors.New("error getting latest gitea release, url, owner or repo are unset")
+	}
+
+	if client == nil {
+		client = http.DefaultClient
+	}
+	api := &giteaAPI{
+		client: client,
+		repoURL: fmt.Sprintf("%s/api/v1/repos/%s/%s", strings.TrimRight(baseURL, "/"),
+			url.PathEscape(owner), url.PathEscape(repo)),
+	}
+
+	gc.forgeChecker, err = newForgeChecker(ctx, "gitea", fmt.Sprintf("repo %s/%s", owner, repo), api, client, policy, current)
+	if err != nil {
+		return nil, err
+	}
+
+	return &gc, nil
+}
+
+// latestRelease gets the latest release, which Gitea tells ignoring drafts and
+// prereleases
+func (a *giteaAPI) latestRelease(ctx context.Context) (*forgeRelease, error) {
+	return a.getRelease(ctx, "/releases/latest")
+}
+
+// releaseByTag gets the release tagged tag
+func (a *giteaAPI) releaseByTag(ctx context.Context, tag string) (*forgeRelease, error) {
+	return a.getRelease(ctx, "/releases/tags/"+url.PathEscape(tag))
+}
+
+// releases lists the most recent releases, ignoring drafts (that Gitea lists to users
+// with write access)
+func (a *giteaAPI) releases(ctx context.Context) ([]*forgeRelease, error) {
+	var releases []giteaRelease
+	err := getJSON(ctx, a.client, fmt.Sprintf("%s/releases?limit=%d", a.repoURL, maxChannelReleases), &releases)
+	if err != nil {
+		return nil, err
+	}
+
+	frs := []*forgeRelease{}
+	for i := range releases {
+		if !releases[i].Draft {
+			frs = append(frs, releases[i].forgeRelease())
+		}
+	}
+
+	return frs, nil
+}
+
+// getRelease gets the release at the repo API path p
+func (a *giteaAPI) getRelease(ctx context.Context, p string) (*forgeRelease, error) {
+	release := giteaRelease{}
+	err := getJSON(ctx, a.client, a.repoURL+p, &release)
+	if err != nil {
+		return nil, err
+	}
+
+	return release.forgeRelease(), nil
+}
@@ -0,0 +1,123 @@
+package version_test
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/dgmorales/go-cli-selfupdate/gitea"
+	"github.com/dgmorales/go-cli-selfupdate/version"
+)
+
+const fakeGiteaToken = "gitea-not-really-a-token"
+
+// newGiteaMock starts a local stand-in for the Gitea Releases API, serving
+// fakeOrg/fakeRepo with latest release set to latestV (or no releases at all, if latestV
+// is blank)
+//
+// If private is true, it answers as Gitea does for private repos (404) unless the request
+// carries the fake token. That includes asset downloads.
+func newGiteaMock(t *testing.T, latestV string, private bool) *httptest.Server {
+	t.Helper()
+
+	var srv *httptest.Server
+	srv = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if private && r.Header.Get("Authorization") != "token "+fakeGiteaToken {
+			http.NotFound(w, r)
+			return
+		}
+
+		switch {
+		case r.URL.Path == fmt.Sprintf("/api/v1/repos/%s/%s/releases/latest", fakeOrg, fakeRepo):
+			if strings.TrimSpace(latestV) == "" {
+				http.NotFound(w, r)
+				return
+			}
+
+			assets := []map[string]interface{}{}
+			for i, goos := range []string{"darwin", "linux", "windows"} {
+				name := fakeAssetName(latestV, goos)
+				assets = append(assets, map[string]interface{}{
+					"id":                   100 + i,
+					"name":                 name,
+					"browser_download_url": fmt.Sprintf("%s/%s/%s/releases/download/%s/%s", srv.URL, fakeOrg, fakeRepo, latestV, name),
+				})
+			}
+			json.NewEncoder(w).Encode(map[string]interface{}{
+				"tag_name": latestV,
+				"assets":   assets,
+			})
+
+		case strings.HasPrefix(r.URL.Path, fmt.Sprintf("/%s/%s/releases/download/", fakeOrg, fakeRepo)):
+			fmt.Fprint(w, fakeAssetContent)
+
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	t.Cleanup(srv.Close)
+
+	return srv
+}
+
+func TestGiteaCheckerImplementsChecker(t *testing.T) {
+	var i interface{} = new(version.GiteaChecker)
+	if _, ok := i.(version.Checker); !ok {
+		t.Fatalf("expected %T to implement version.Checker", i)
+	}
+}
+
+func TestGiteaCheckerCheck(t *testing.T) {
+	for _, tC := range checkCases {
+		t.Run(tC.desc, func(t *testing.T) {
+			srv := newGiteaMock(t, tC.latest, false)
+
+			gc, err := version.NewGiteaChecker(context.Background(), srv.Client(), srv.URL, fakeOrg, fakeRepo, version.Policy{MinimalRequired: tC.min}, tC.cur)
+			if err != nil {
+				t.Fatalf("expected nil error, got %s", err)
+			}
+
+			ans := gc.Check()
+			if ans != tC.vAssert {
+				t.Errorf("expected '%d/%s', got '%d/%s'", tC.vAssert, assertStr(tC.vAssert), ans, assertStr(ans))
+			}
+		})
+	}
+}
+
+func TestNewGiteaCheckerFailsWithInvalidLatestVersion(t *testing.T) {
+	srv := newGiteaMock(t, "silver", false)
+
+	_, err := version.NewGiteaChecker(context.Background(), srv.Client(), srv.URL, fakeOrg, fakeRepo, version.Policy{MinimalRequired: "v1"}, "v2")
+	if err == nil {
+		t.Errorf("expected error, got nil")
+	}
+}
+
+func TestGiteaCheckerDownloadLatestFromPrivateRepo(t *testing.T) {
+	srv := newGiteaMock(t, "v3", true)
+
+	t.Setenv("GITEA_TOKEN", fakeGiteaToken)
+	client, err := gitea.NewClient(srv.URL)
+	if err != nil {
+		t.Fatalf("expected nil error creating client, got %s", err)
+	}
+
+	gc, err := version.NewGiteaChecker(context.Background(), client, srv.URL, fakeOrg, fakeRepo, version.Policy{MinimalRequired: "v1"}, "v2")
+	if err != nil {
+		t.Fatalf("expected nil error, got %s", err)
+	}
+
+	verifyExpectedVersions(t, gc, versionsCaseSpec{min: "v1", cur: "v2", latest: "v3", expMin: "1.0.0", expCur: "2.0.0", expLatest: "3.0.0"})
+
+	asset, err := gc.DownloadLatest(context.Background())
+	if err != nil {
+		t.Fatalf("expected nil error on download, got %s", err)
+	}
+
+	verifyDownloadedFile(t, asset)
+}
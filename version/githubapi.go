@@ -0,0 +1,131 @@
+package version
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"math/rand"
+	"net"
+	"net/http"
+	"time"
+
+	"github.com/google/go-github/v48/github"
+)
+
+// maxAPIRetries is how many times we retry a GitHub API call failing with a transient
+// error (a server error or a network failure)
+const maxAPIRetries = 3
+
+// apiRetryDelay is how long we wait before the first retry of a GitHub API call. It
+// doubles on each retry after that, with jitter.
+var apiRetryDelay = 500 * time.Millisecond
+
+// anonymousRateLimit is the GitHub API rate limit (per hour) of anonymous requests
+const anonymousRateLimit = 60
+
+// callAPI calls the GitHub API with call, retrying it with exponential backoff (and
+// jitter) if it fails with a transient error. It logs the rate limit state after each
+// response, and turns rate limit errors into errors telling when the limit resets.
+func callAPI(ctx context.Context, call func() (*github.Response, error)) error {
+	delay := apiRetryDelay
+
+	for retries := 0; ; retries++ {
+		resp, err := call()
+		logRate(resp)
+		if err == nil {
+			return nil
+		}
+
+		if !isTransient(err) || ctx.Err() != nil || retries == maxAPIRetries {
+			return rateLimitError(err)
+		}
+
+		// full delay plus up to half of it again, so concurrent runs spread out
+		wait := delay + time.Duration(rand.Int63n(int64(delay)/2+1))
+		log.Printf("github api call failed: %s. Retrying in %s", err, wait.Round(time.Millisecond))
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(wait):
+		}
+		delay *= 2
+	}
+}
+
+// isTransient tells if err (from a GitHub API call) is worth retrying: server errors and
+// network failures are, rate limits and anything else are not
+func isTransient(err error) bool {
+	var rateErr *github.RateLimitError
+	var abuseErr *github.AbuseRateLimitError
+	if errors.As(err, &rateErr) || errors.As(err, &abuseErr) {
+		return false
+	}
+
+	var ghErr *github.ErrorResponse
+	if errors.As(err, &ghErr) {
+		return ghErr.Response != nil && ghErr.Response.StatusCode >= http.StatusInternalServerError
+	}
+
+	var netErr net.Error
+	return errors.As(err, &netErr)
+}
+
+// RateLimitedError means the GitHub API rate limit was exceeded. It wraps the
+// github.RateLimitError (or github.AbuseRateLimitError, for secondary rate limits).
+type RateLimitedError struct {
+	// Reset is when we may call the API again
+	Reset time.Time
+
+	msg string
+	err error
+}
+
+func (e *RateLimitedError) Error() string {
+	return e.msg
+}
+
+func (e *RateLimitedError) Unwrap() error {
+	return e.err
+}
+
+// rateLimitError returns err as a RateLimitedError, telling when the rate limit resets,
+// if it is a rate limit error. Otherwise, it returns err as is.
+func rateLimitError(err error) error {
+	var rateErr *github.RateLimitError
+	if errors.As(err, &rateErr) {
+		reset := rateErr.Rate.Reset.Time
+		msg := fmt.Sprintf("github api rate limit exceeded (%d requests per hour), it resets at %s (in %s)",
+			rateErr.Rate.Limit, reset.Local().Format("15:04:05"), time.Until(reset).Round(time.Second))
+		if rateErr.Rate.Limit <= anonymousRateLimit {
+			msg += ". Set GITHUB_TOKEN for a higher limit"
+		}
+		return &RateLimitedError{Reset: reset, msg: msg, err: err}
+	}
+
+	var abuseErr *github.AbuseRateLimitError
+	if errors.As(err, &abuseErr) {
+		if abuseErr.RetryAfter == nil {
+			return &RateLimitedError{msg: "github api secondary rate limit exceeded, try again later", err: err}
+		}
+		wait := abuseErr.GetRetryAfter()
+		return &RateLimitedError{
+			Reset: time.Now().Add(wait),
+			msg:   fmt.Sprintf("github api secondary rate limit exceeded, try again in %s", wait.Round(time.Second)),
+			err:   err,
+		}
+	}
+
+	return err
+}
+
+// logRate logs the rate limit state from resp, if it has one (responses from our
+// cache do not)
+func logRate(resp *github.Response) {
+	if resp == nil || resp.Rate.Limit == 0 {
+		return
+	}
+
+	log.Printf("github api rate limit: %d of %d requests remaining, resets at %s",
+		resp.Rate.Remaining, resp.Rate.Limit, resp.Rate.Reset.Local().Format(time.RFC3339))
+}
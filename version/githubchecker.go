@@ -8,31 +8,73 @@ import (
 	"io"
 	"log"
 	"net/http"
-	"os"
-	"path"
-	"path/filepath"
-	"runtime"
+	"net/url"
 	"strings"
 
 	"github.com/google/go-github/v48/github"
 	semver "github.com/hashicorp/go-version"
 )
 
+// GitHubChecker is a Checker for releases published as GitHub Releases
 type GitHubChecker struct {
+	versionSet
+	downloader
 	client    *github.Client
-	minimal   *semver.Version
-	current   *semver.Version
-	latest    *semver.Version
 	repoOwner string
 	repoName  string
-	assetName string
-	assetID   int64
+	channel   Channel
+	asset     githubAsset
+	assetErr  error
 }
 
-// DiscoverLatest discovers what is the latest version from GitHub Releases
+// githubAsset identifies the release asset for our platform, along with the release
+// checksum manifest, the binary signature and the patch from the current version, if any
+type githubAsset struct {
+	name        string
+	id          int64
+	checksumsID int64
+	signatureID int64
+	patchName   string
+	patchID     int64
+}
+
+// patch returns the patch from the current version as a release asset on its own
+func (a githubAsset) patch() githubAsset {
+	return githubAsset{name: a.patchName, id: a.patchID, checksumsID: a.checksumsID, signatureID: a.signatureID}
+}
+
+// maxAssetRedirects limits redirects when downloading release assets, just like
+// http.Client does
+const maxAssetRedirects = 10
+
+// maxChannelReleases is how many of the most recent releases we look at when looking for
+// the latest release in a channel other than stable, or for a release policy allows
+const maxChannelReleases = 100
+
+// maxChangelogPages limits how many pages of releases (of maxChannelReleases each) we
+// look at for the changelog
+const maxChangelogPages = 5
+
+// NewGithubChecker discovers what is the latest version from GitHub Releases
 //
 // It already saves asset information, leaving everything ready for calling Download()
-func NewGithubChecker(client *github.Client, owner string, repo string, minimalReq string, current string) (*GitHubChecker, error) {
+func NewGithubChecker(ctx context.Context, client *github.Client, owner string, repo string, minimalReq string, current string) (*GitHubChecker, error) {
+	return NewGithubChannelChecker(ctx, client, owner, repo, ChannelStable, Policy{MinimalRequired: minimalReq}, current)
+}
+
+// NewGithubChannelChecker discovers what is the latest version from GitHub Releases in the
+// given release channel
+//
+// For the stable channel, the latest version is the GitHub latest release. For other
+// channels, it is the highest version among the most recent releases (excluding drafts)
+// that are part of the channel. If policy pins or caps versions, the pinned (or maximal
+// allowed) release is taken as latest instead. Releases policy does not allow (revoked,
+// or outside the version constraint) are never taken as latest: the newest allowed
+// release below them is.
+//
+// It already saves asset information, leaving everything ready for calling Download().
+// Failing to get it in time (before ctx is done) leaves the latest version unknown.
+func NewGithubChannelChecker(ctx context.Context, client *github.Client, owner string, repo string, channel Channel, policy Policy, current string) (*GitHubChecker, error) {
 	var err error
 	ghc := GitHubChecker{}
 
@@ -41,26 +83,20 @@ func NewGithubChecker(client *github.Client, owner string, repo string, minimalR
 	}
 	ghc.repoOwner = owner
 	ghc.repoName = repo
+	ghc.channel = channel
 
-	ghc.current, err = semver.NewSemver(current)
+	ghc.versionSet, err = newVersionSet(policy, current)
 	if err != nil {
 		return nil, err
 	}
 
-	if strings.TrimSpace(minimalReq) != "" {
-		ghc.minimal, err = semver.NewSemver(minimalReq)
-		if err != nil {
-			return nil, err
-		}
-	}
-
 	if client == nil {
 		ghc.client = github.NewClient(nil)
 	} else {
 		ghc.client = client
 	}
 
-	latest, _, err := ghc.client.Repositories.GetLatestRelease(context.Background(), owner, repo)
+	latest, err := ghc.getTargetRelease(ctx, channel)
 	if err != nil {
 		// This special handling bellow with error.As is necessary because we want to log
 		// the error message, and that triggers a weird bug with github.ErrorResponse
@@ -69,138 +105,449 @@ func NewGithubChecker(client *github.Client, owner string, repo string, minimalR
 		// There is an issue open there (with no solution at the time of this writing):
 		// https://github.com/migueleliasweb/go-github-mock/issues/6
 		var ghErr *github.ErrorResponse
-		var errStr string
-
 		if errors.As(err, &ghErr) {
-			errStr = ghErr.Message
-		} else {
-			errStr = err.Error()
+			err = errors.New(ghErr.Message)
 		}
 
 		// We won't error if we can't get the latest version.
 		// Instead we will proceed without the latest version (and assets) info
-		log.Printf("error getting latest github release from repo %s/%s: %s. Will ignore and continue with latest version as unknown",
-			owner, repo, errStr)
+		ghc.latestErr = fmt.Errorf("error getting latest github release from repo %s/%s (%s channel): %w",
+			owner, repo, channel, err)
+		log.Printf("%s. Will ignore and continue with latest version as unknown", ghc.latestErr)
 		return &ghc, nil
 	}
 
-	ghc.latest, err = semver.NewSemver(latest.GetTagName())
+	err = ghc.setLatest(latest.GetTagName())
 	if err != nil {
 		return nil, err
 	}
 
-	// asset must contain goos string (linux|windows|darwin) on filename
-	for _, asset := range latest.Assets {
-		if strings.Contains(asset.GetName(), runtime.GOOS) {
-			ghc.assetName = asset.GetName()
-			ghc.assetID = asset.GetID()
-		}
+	ghc.asset, ghc.assetErr = ghc.releaseAsset(latest)
+	if ghc.assetErr != nil {
+		log.Printf("error picking github release asset: %s", ghc.assetErr)
 	}
+	ghc.release = ReleaseInfo{Asset: ghc.asset.name, URL: latest.GetHTMLURL()}
 
 	return &ghc, err
 }
 
-// Current returns current version as string
-func (c *GitHubChecker) Current() string {
-	if c == nil {
-		return ""
+// releaseAsset returns the release asset for the platform we are running on
+func (c *GitHubChecker) releaseAsset(release *github.RepositoryRelease) (githubAsset, error) {
+	v, err := semver.NewSemver(release.GetTagName())
+	if err != nil {
+		return githubAsset{}, err
+	}
+
+	names := make([]string, len(release.Assets))
+	for i, asset := range release.Assets {
+		names[i] = asset.GetName()
+	}
+
+	i, err := c.platformAsset(names, v)
+	if err != nil {
+		return githubAsset{}, err
+	}
+
+	asset := githubAsset{name: release.Assets[i].GetName(), id: release.Assets[i].GetID()}
+	if i := checksumManifest(names); i >= 0 {
+		asset.checksumsID = release.Assets[i].GetID()
+	}
+	if i := signatureAsset(names, asset.name); i >= 0 {
+		asset.signatureID = release.Assets[i].GetID()
+	}
+	if i := c.patchAsset(names, asset.name); i >= 0 {
+		asset.patchName, asset.patchID = names[i], release.Assets[i].GetID()
+	}
+
+	return asset, nil
+}
+
+// getTargetRelease gets the release users should be running: the latest one in channel,
+// unless policy pins or caps versions. If policy does not allow it (like when it is
+// revoked), the newest older release it allows is taken instead.
+func (c *GitHubChecker) getTargetRelease(ctx context.Context, channel Channel) (*github.RepositoryRelease, error) {
+	if c.pinned != nil {
+		return c.getReleaseByVersion(ctx, c.pinned)
+	}
+
+	latest, err := c.getLatestRelease(ctx, channel)
+	if err != nil {
+		return nil, err
+	}
+
+	latestV, err := semver.NewSemver(latest.GetTagName())
+	if err != nil {
+		// let the caller complain about it
+		return latest, nil
+	}
+
+	if v := c.limit(latestV); v != nil {
+		latest, err = c.getReleaseByVersion(ctx, v)
+		if err != nil {
+			return nil, err
+		}
+		latestV = v
+	}
+
+	if !c.allowed(latestV) {
+		return c.getFallbackRelease(ctx, channel, latestV)
 	}
-	return c.current.String()
+
+	return latest, nil
 }
 
-// Minimal returns minimal required version as string
-func (c *GitHubChecker) Minimal() string {
-	if c == nil || c.minimal == nil {
-		return ""
+// getFallbackRelease gets the newest release in channel below version v that policy
+// allows, among the most recent ones
+func (c *GitHubChecker) getFallbackRelease(ctx context.Context, channel Channel, v *semver.Version) (*github.RepositoryRelease, error) {
+	var releases []*github.RepositoryRelease
+	err := callAPI(ctx, func() (resp *github.Response, err error) {
+		releases, resp, err = c.client.Repositories.ListReleases(ctx, c.repoOwner, c.repoName,
+			&github.ListOptions{PerPage: maxChannelReleases})
+		return resp, err
+	})
+	if err != nil {
+		return nil, err
 	}
-	return c.minimal.String()
+
+	versions := make([]*semver.Version, len(releases))
+	for i, r := range releases {
+		rv, err := semver.NewSemver(r.GetTagName())
+		if r.GetDraft() || err != nil || !channel.includes(rv) {
+			continue
+		}
+		versions[i] = rv
+	}
+
+	i := c.fallback(v, versions)
+	if i < 0 {
+		return nil, fmt.Errorf("%w in %s channel (%s is not)", errNoAllowedRelease, channel, v)
+	}
+
+	return releases[i], nil
 }
 
-// Latest returns latest version as string
-func (c *GitHubChecker) Latest() string {
-	if c == nil {
-		return ""
+// getReleaseByVersion gets the release of version v, looking it up by tag name
+func (c *GitHubChecker) getReleaseByVersion(ctx context.Context, v *semver.Version) (release *github.RepositoryRelease, err error) {
+	for _, tag := range releaseTags(v) {
+		err = callAPI(ctx, func() (resp *github.Response, err error) {
+			release, resp, err = c.client.Repositories.GetReleaseByTag(ctx, c.repoOwner, c.repoName, tag)
+			return resp, err
+		})
+		if err == nil {
+			return release, nil
+		}
 	}
-	return c.latest.String()
+
+	return nil, err
 }
 
-func openFileForDownload(name string) (filename string, fd *os.File, err error) {
-	filename = path.Join(os.TempDir(), fmt.Sprintf("%s-%s", filepath.Base(os.Args[0]), name))
+// getLatestRelease gets the latest release in channel
+func (c *GitHubChecker) getLatestRelease(ctx context.Context, channel Channel) (*github.RepositoryRelease, error) {
+	if channel == ChannelStable {
+		var latest *github.RepositoryRelease
+		err := callAPI(ctx, func() (resp *github.Response, err error) {
+			latest, resp, err = c.client.Repositories.GetLatestRelease(ctx, c.repoOwner, c.repoName)
+			return resp, err
+		})
+		return latest, err
+	}
 
-	fd, err = os.Create(filename)
+	// Releases are listed newest first
+	var releases []*github.RepositoryRelease
+	err := callAPI(ctx, func() (resp *github.Response, err error) {
+		releases, resp, err = c.client.Repositories.ListReleases(ctx, c.repoOwner, c.repoName,
+			&github.ListOptions{PerPage: maxChannelReleases})
+		return resp, err
+	})
 	if err != nil {
-		return filename, nil, fmt.Errorf("error creating file for release download: %w", err)
+		return nil, err
 	}
 
-	return filename, fd, nil
+	var latest *github.RepositoryRelease
+	var latestV *semver.Version
+	for _, r := range releases {
+		if r.GetDraft() {
+			continue
+		}
+
+		v, err := semver.NewSemver(r.GetTagName())
+		if err != nil {
+			log.Printf("ignoring github release %s: %s", r.GetTagName(), err)
+			continue
+		}
+
+		if channel.includes(v) && (latestV == nil || v.GreaterThan(latestV)) {
+			latest, latestV = r, v
+		}
+	}
+
+	if latest == nil {
+		return nil, fmt.Errorf("%w in %s channel", errNoReleases, channel)
+	}
+
+	return latest, nil
 }
 
-// DownloadLatest downloads the saved GitHub Release Asset to a temporary file
-func (c *GitHubChecker) DownloadLatest() (filename string, err error) {
-	var httpClient http.Client
+// Changelog returns the notes of the GitHub releases (in our channel) above the current
+// version, up to the latest one, newest first
+func (c *GitHubChecker) Changelog(ctx context.Context) ([]ReleaseNotes, error) {
+	if c == nil {
+		return nil, fmt.Errorf("in GitHubChecker.Changelog: called with nil receiver")
+	}
+	if c.latest == nil {
+		return nil, errors.New("latest version is unknown")
+	}
+
+	var notes []releaseNote
+	opts := github.ListOptions{PerPage: maxChannelReleases}
+	for page := 0; page < maxChangelogPages; page++ {
+		var releases []*github.RepositoryRelease
+		var resp *github.Response
+		err := callAPI(ctx, func() (_ *github.Response, err error) {
+			releases, resp, err = c.client.Repositories.ListReleases(ctx, c.repoOwner, c.repoName, &opts)
+			return resp, err
+		})
+		if err != nil {
+			return nil, err
+		}
+
+		// Releases are listed newest first, so we are done once we reach older ones
+		done := false
+		for _, r := range releases {
+			v, err := semver.NewSemver(r.GetTagName())
+			if r.GetDraft() || err != nil || !c.channel.includes(v) {
+				continue
+			}
+			if !v.GreaterThan(c.current) {
+				done = true
+			}
+			if c.inChangelog(v) {
+				notes = append(notes, releaseNote{
+					ReleaseNotes: ReleaseNotes{Version: v.String(), Name: r.GetName(), Body: r.GetBody(), URL: r.GetHTMLURL()},
+					v:            v,
+				})
+			}
+		}
 
+		if done || resp.NextPage == 0 {
+			break
+		}
+		opts.Page = resp.NextPage
+	}
+
+	return c.changelog(notes), nil
+}
+
+// DownloadLatest downloads the saved GitHub Release Asset to a temporary file,
+// verifying it against the release checksum manifest, if any
+func (c *GitHubChecker) DownloadLatest(ctx context.Context) (*Asset, error) {
 	if c == nil {
-		return "", fmt.Errorf("in GitHubChecker.DownloadLatest: called with nil receiver")
+		return nil, fmt.Errorf("in GitHubChecker.DownloadLatest: called with nil receiver")
 	}
 
-	if c.assetName == "" || c.assetID == 0 {
-		return "", errors.New("in Download: github release asset information is unavailable")
+	if c.assetErr != nil {
+		return nil, fmt.Errorf("in Download: %w", c.assetErr)
 	}
 
-	filename, f, err := openFileForDownload(c.assetName)
+	if c.asset.name == "" || c.asset.id == 0 {
+		return nil, errors.New("in Download: github release asset information is unavailable")
+	}
+
+	return c.download(ctx, c.asset)
+}
+
+// DownloadVersion downloads the GitHub Release Asset of version v to a temporary file,
+// looking the release up by tag name. It is verified against the release checksum
+// manifest, if any.
+func (c *GitHubChecker) DownloadVersion(ctx context.Context, v string) (*Asset, error) {
+	if c == nil {
+		return nil, fmt.Errorf("in GitHubChecker.DownloadVersion: called with nil receiver")
+	}
+
+	asset, err := c.versionAsset(ctx, v)
 	if err != nil {
-		return filename, err
+		return nil, err
+	}
+
+	return c.download(ctx, asset)
+}
+
+// DownloadPatch downloads the GitHub Release Asset with the patch from the current
+// version to version v to a temporary file. It is verified against the release checksum
+// manifest, that must list the patched binary too.
+func (c *GitHubChecker) DownloadPatch(ctx context.Context, v string) (*Asset, error) {
+	if c == nil {
+		return nil, fmt.Errorf("in GitHubChecker.DownloadPatch: called with nil receiver")
+	}
+
+	asset := c.asset
+	if !c.isLatest(v) || c.assetErr != nil {
+		var err error
+		asset, err = c.versionAsset(ctx, v)
+		if err != nil {
+			return nil, err
+		}
 	}
-	defer f.Close()
 
-	data, redirectUrl, err := c.client.Repositories.DownloadReleaseAsset(context.Background(),
-		c.repoOwner, c.repoName, c.assetID, &httpClient)
-	if redirectUrl != "" {
-		return filename, fmt.Errorf("in Download: got unsupported asset redirect URL (%s)", redirectUrl)
+	if asset.patchID == 0 || asset.checksumsID == 0 {
+		return nil, ErrNoPatch
 	}
+
+	return patched(c.download(ctx, asset.patch()))
+}
+
+// versionAsset returns the release asset of version v, looking the release up by tag
+// name
+func (c *GitHubChecker) versionAsset(ctx context.Context, v string) (githubAsset, error) {
+	want, err := semver.NewSemver(v)
+	if err != nil {
+		return githubAsset{}, fmt.Errorf("in Download: %w", err)
+	}
+
+	release, err := c.getReleaseByVersion(ctx, want)
 	if err != nil {
-		return filename, fmt.Errorf("in Download: %w", err)
+		return githubAsset{}, fmt.Errorf("in Download: error getting github release %s: %w", v, err)
 	}
 
-	_, err = io.Copy(f, data)
+	asset, err := c.releaseAsset(release)
 	if err != nil {
-		return filename, fmt.Errorf("in Download: %w", err)
+		return githubAsset{}, fmt.Errorf("in Download: github release %s: %w", v, err)
 	}
 
-	return filename, nil
+	return asset, nil
 }
 
-// Check discovers if the current version can or must be updated.
-//
-// More precisely, it checks in which version.Assertion case the current version falls
-// in.
-//
-// It tries to be resilient and always return the best assertion it can about the
-// current version. So for example, if the minimal version is unknown it will ignore
-// that check and check against the latest.
-//
-// If it cannot tell anything, it returns is IsUnknown.
-func (c *GitHubChecker) Check() Assertion {
-	if c == nil || c.current == nil {
-		// return now otherwise we would panic trying the comparisons bellow
-		return IsUnknown
+// download downloads asset (and its signature, if any) to temporary files, verifying it
+// against the release checksum manifest, if any
+func (c *GitHubChecker) download(ctx context.Context, asset githubAsset) (*Asset, error) {
+	filename, err := c.downloadAsset(ctx, asset.id, asset.name)
+	if err != nil {
+		return nil, err
 	}
 
-	if c.latest != nil && c.current.Equal(c.latest) {
-		return IsLatest
+	a, err := c.checkAsset(ctx, asset, filename)
+	if err != nil {
+		return nil, err
 	}
 
-	if c.minimal != nil && c.current.LessThan(c.minimal) {
-		return MustUpdate
+	if asset.signatureID != 0 {
+		a.Signature, err = c.downloadAsset(ctx, asset.signatureID, signatureName(asset.name))
+		if err != nil {
+			return nil, err
+		}
 	}
 
-	if c.latest != nil && c.current.LessThan(c.latest) {
-		return CanUpdate
+	return a, nil
+}
+
+// downloadAsset downloads the GitHub Release Asset id to a temporary file named after
+// name
+func (c *GitHubChecker) downloadAsset(ctx context.Context, id int64, name string) (filename string, err error) {
+	return c.fetch(ctx, c.assetOpener(ctx, id), name)
+}
+
+// checkAsset verifies asset, downloaded to filename, against the release checksum
+// manifest, if any
+func (c *GitHubChecker) checkAsset(ctx context.Context, asset githubAsset, filename string) (*Asset, error) {
+	if asset.checksumsID == 0 {
+		log.Printf("no checksum manifest in github release, %s will not be verified", asset.name)
+		return checkedAsset(filename, 0, "", "")
 	}
 
-	if c.latest != nil && c.current.GreaterThan(c.latest) {
-		return IsBeyond
+	sums, err := c.openAsset(ctx, asset.checksumsID)
+	if err != nil {
+		return nil, fmt.Errorf("in Download: error getting release checksum manifest: %w", err)
+	}
+	defer sums.Close()
+
+	sumsData, err := io.ReadAll(sums)
+	if err != nil {
+		return nil, fmt.Errorf("in Download: error getting release checksum manifest: %w", err)
+	}
+
+	return assetFromChecksums(filename, asset.name, sumsData)
+}
+
+// openAsset opens the GitHub Release Asset id for reading its content
+func (c *GitHubChecker) openAsset(ctx context.Context, id int64) (io.ReadCloser, error) {
+	resp, err := c.assetOpener(ctx, id)(0)
+	if err != nil {
+		return nil, err
+	}
+
+	if resp.StatusCode != http.StatusOK {
+		resp.Body.Close()
+		return nil, fmt.Errorf("unexpected status getting github release asset %d: %s", id, resp.Status)
+	}
+
+	return resp.Body, nil
+}
+
+// assetOpener opens downloads of the GitHub Release Asset id.
+//
+// GitHub redirects asset downloads to its storage (see followRedirects), which supports
+// ranges. Assets served directly (with no redirect) are always downloaded whole.
+func (c *GitHubChecker) assetOpener(ctx context.Context, id int64) opener {
+	return func(offset int64) (*http.Response, error) {
+		data, redirectURL, err := c.client.Repositories.DownloadReleaseAsset(ctx,
+			c.repoOwner, c.repoName, id, nil)
+		if err != nil {
+			return nil, err
+		}
+
+		if redirectURL != "" {
+			return c.followRedirects(ctx, redirectURL, offset)
+		}
+
+		return &http.Response{Status: "200 OK", StatusCode: http.StatusOK, Body: data, ContentLength: -1}, nil
+	}
+}
+
+// followRedirects opens the download of a release asset redirected to loc, following any
+// further redirects.
+//
+// Requests to the GitHub API host (like the storage of some GHE setups) carry our
+// credentials. Requests to other hosts (like GitHub's CDN) do not: the redirect URL
+// itself grants access to the asset there, and some storage services refuse requests
+// with credentials of another kind.
+func (c *GitHubChecker) followRedirects(ctx context.Context, loc string, offset int64) (*http.Response, error) {
+	noRedirects := func(*http.Request, []*http.Request) error { return http.ErrUseLastResponse }
+
+	// Client returns a copy, that we can change
+	authClient := c.client.Client()
+	authClient.CheckRedirect = noRedirects
+	plainClient := &http.Client{CheckRedirect: noRedirects}
+
+	for i := 0; i < maxAssetRedirects; i++ {
+		u, err := url.Parse(loc)
+		if err != nil {
+			return nil, fmt.Errorf("invalid asset redirect url %q: %w", loc, err)
+		}
+
+		client := plainClient
+		if u.Scheme == c.client.BaseURL.Scheme && strings.EqualFold(u.Host, c.client.BaseURL.Host) {
+			client = authClient
+		}
+
+		resp, err := urlOpener(ctx, client, loc)(offset)
+		if err != nil {
+			return nil, err
+		}
+
+		switch resp.StatusCode {
+		case http.StatusMovedPermanently, http.StatusFound, http.StatusSeeOther,
+			http.StatusTemporaryRedirect, http.StatusPermanentRedirect:
+		default:
+			return resp, nil
+		}
+
+		next, err := resp.Location()
+		resp.Body.Close()
+		if err != nil {
+			return nil, fmt.Errorf("asset redirect with no location: %w", err)
+		}
+		loc = next.String()
 	}
 
-	return IsUnknown
+	return nil, fmt.Errorf("stopped after %d asset redirects", maxAssetRedirects)
 }
@@ -1,14 +1,23 @@
 package version_test
 
 import (
+	"bytes"
+	"context"
+	"crypto/sha256"
 	"errors"
 	"fmt"
 	"io/ioutil"
 	"net/http"
+	"net/http/httptest"
+	"net/url"
 	"os"
+	"path"
+	"runtime"
 	"strings"
 	"testing"
+	"time"
 
+	"github.com/dgmorales/go-cli-selfupdate/gh"
 	"github.com/dgmorales/go-cli-selfupdate/logger"
 	"github.com/dgmorales/go-cli-selfupdate/version"
 	"github.com/google/go-github/v48/github"
@@ -37,6 +46,16 @@ func unPrefixV(s string) string {
 	return strings.TrimLeft(s, "v")
 }
 
+// fakeAssetName names the fake release asset of version v for goos (and our GOARCH), the
+// way our release workflow does
+func fakeAssetName(v string, goos string) string {
+	ext := "tar.gz"
+	if goos == "windows" {
+		ext = "zip"
+	}
+	return fmt.Sprintf("test-v%s-%s-%s.%s", unPrefixV(v), goos, runtime.GOARCH, ext)
+}
+
 func strp(s string) *string {
 	return &s
 }
@@ -55,34 +74,43 @@ func assertStr(a version.Assertion) string {
 		return "must update"
 	case version.IsBeyond:
 		return "is beyond"
+	case version.MustDowngrade:
+		return "must downgrade"
+	case version.IsRevoked:
+		return "is revoked"
 	}
 
 	return "is unknown"
 }
 
+// fakeGitHubRelease returns a release tagged as tag, with assets for some platforms
+func fakeGitHubRelease(tag string) github.RepositoryRelease {
+	return github.RepositoryRelease{
+		TagName: strp(tag),
+		Assets: []*github.ReleaseAsset{
+			{
+				ID:   int64p(100),
+				Name: strp(fakeAssetName(tag, "darwin")),
+			},
+			{
+				ID:   int64p(101),
+				Name: strp(fakeAssetName(tag, "linux")),
+			},
+			{
+				ID:   int64p(102),
+				Name: strp(fakeAssetName(tag, "windows")),
+			},
+		},
+	}
+}
+
 // newReleasesGitHubMock simulates a scenario where repo has latest
 // release set to latestV
 func newReleasesGitHubMock(latestV string) *github.Client {
 	m := mock.NewMockedHTTPClient(
 		mock.WithRequestMatch(
 			mock.GetReposReleasesLatestByOwnerByRepo,
-			github.RepositoryRelease{
-				TagName: strp(latestV),
-				Assets: []*github.ReleaseAsset{
-					{
-						ID:   int64p(100),
-						Name: strp(fmt.Sprintf("test-v%s-darwin.tar.gz", unPrefixV(latestV))),
-					},
-					{
-						ID:   int64p(101),
-						Name: strp(fmt.Sprintf("test-v%s-linux.tar.gz", unPrefixV(latestV))),
-					},
-					{
-						ID:   int64p(102),
-						Name: strp(fmt.Sprintf("test-v%s-windows.zip", unPrefixV(latestV))),
-					},
-				},
-			},
+			fakeGitHubRelease(latestV),
 		),
 		mock.WithRequestMatch(
 			mock.GetReposReleasesAssetsByOwnerByRepoByAssetId,
@@ -112,6 +140,77 @@ func newNotReleasedGitHubMock() *github.Client {
 	return github.NewClient(m)
 }
 
+// newChannelsGitHubMock simulates a scenario where repo has the given releases, in
+// any channel. Tags in drafts are drafts.
+//
+// The latest release is latestV, as GitHub only considers stable releases for that.
+func newChannelsGitHubMock(latestV string, tags []string, drafts []string) *github.Client {
+	releases := []github.RepositoryRelease{}
+	for _, tag := range tags {
+		releases = append(releases, github.RepositoryRelease{TagName: strp(tag)})
+	}
+	for _, tag := range drafts {
+		draft := true
+		releases = append(releases, github.RepositoryRelease{TagName: strp(tag), Draft: &draft})
+	}
+
+	m := mock.NewMockedHTTPClient(
+		mock.WithRequestMatch(
+			mock.GetReposReleasesLatestByOwnerByRepo,
+			github.RepositoryRelease{TagName: strp(latestV)},
+		),
+		mock.WithRequestMatch(
+			mock.GetReposReleasesByOwnerByRepo,
+			releases,
+		),
+	)
+
+	return github.NewClient(m)
+}
+
+// newPolicyGitHubMock simulates a scenario where repo has latest release set to latestV,
+// and the releases in tags can be looked up by tag name
+func newPolicyGitHubMock(latestV string, tags []string) *github.Client {
+	m := mock.NewMockedHTTPClient(
+		mock.WithRequestMatch(
+			mock.GetReposReleasesLatestByOwnerByRepo,
+			github.RepositoryRelease{TagName: strp(latestV)},
+		),
+		mock.WithRequestMatchHandler(
+			mock.GetReposReleasesByOwnerByRepo,
+			http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				// newest first
+				releases := []github.RepositoryRelease{}
+				for i := len(tags) - 1; i >= 0; i-- {
+					releases = append(releases, fakeGitHubRelease(tags[i]))
+				}
+				w.Write(mock.MustMarshal(releases))
+			}),
+		),
+		mock.WithRequestMatchHandler(
+			mock.GetReposReleasesTagsByOwnerByRepoByTag,
+			http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				tag := path.Base(r.URL.Path)
+				for _, t := range tags {
+					if t == tag {
+						w.Write(mock.MustMarshal(fakeGitHubRelease(tag)))
+						return
+					}
+				}
+				mock.WriteError(w, http.StatusNotFound, "Not Found")
+			}),
+		),
+		mock.WithRequestMatchHandler(
+			mock.GetReposReleasesAssetsByOwnerByRepoByAssetId,
+			http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				fmt.Fprint(w, fakeAssetContent)
+			}),
+		),
+	)
+
+	return github.NewClient(m)
+}
+
 func newGitHubMock(latestV string) *github.Client {
 	if strings.TrimSpace(latestV) == "" {
 		return newNotReleasedGitHubMock()
@@ -121,8 +220,8 @@ func newGitHubMock(latestV string) *github.Client {
 
 }
 
-// verifyExpectedVersions checks that the versions in the input GitHubChecker exactly match min, cur and latest
-func verifyExpectedVersions(t *testing.T, gc *version.GitHubChecker, tc versionsCaseSpec) {
+// verifyExpectedVersions checks that the versions in the input Checker exactly match min, cur and latest
+func verifyExpectedVersions(t *testing.T, gc version.Checker, tc versionsCaseSpec) {
 
 	t.Helper()
 
@@ -189,7 +288,7 @@ func TestNewGitHubCheckerWorksWithValidVersions(t *testing.T) {
 	}
 	for _, tC := range testCases {
 		t.Run(tC.desc, func(t *testing.T) {
-			gc, err := version.NewGithubChecker(newGitHubMock(tC.latest), fakeOrg, fakeRepo, tC.min, tC.cur)
+			gc, err := version.NewGithubChecker(context.Background(), newGitHubMock(tC.latest), fakeOrg, fakeRepo, tC.min, tC.cur)
 			if err != nil {
 				t.Fatalf("expected nil error, got %s", err.Error())
 			}
@@ -228,7 +327,7 @@ func TestNewGitHubCheckerFailsWithInvalidVersions(t *testing.T) {
 	}
 	for _, tC := range testCases {
 		t.Run(tC.desc, func(t *testing.T) {
-			_, err := version.NewGithubChecker(newGitHubMock(tC.latest), fakeOrg, fakeRepo, tC.min, tC.cur)
+			_, err := version.NewGithubChecker(context.Background(), newGitHubMock(tC.latest), fakeOrg, fakeRepo, tC.min, tC.cur)
 			if err == nil {
 				t.Errorf("expected error, got nil")
 			}
@@ -237,161 +336,1163 @@ func TestNewGitHubCheckerFailsWithInvalidVersions(t *testing.T) {
 	}
 }
 
+// checkCases are the Check() cases every Checker implementation must pass
+var checkCases = []versionsCaseSpec{
+	{
+		desc:    "ReturnsLatestWhenCurrentEqualsLatest",
+		min:     "2.1.0",
+		cur:     "2.6.0",
+		latest:  "2.6.0",
+		vAssert: version.IsLatest,
+	},
+	{
+		desc:    "ReturnsMustUpdateWhenCurrentIsBellowMinimal",
+		min:     "2.1.0",
+		cur:     "2.0.9",
+		latest:  "2.6.0",
+		vAssert: version.MustUpdate,
+	},
+	{
+		desc:    "ReturnsMustUpdateWhenCurrentIsBellowMinimalEvenIfLatestIsUnknown",
+		min:     "1.0.0",
+		cur:     "0.4.0",
+		latest:  "",
+		vAssert: version.MustUpdate,
+	},
+	{
+		desc:    "ReturnsCanUpdateWhenCurrentIsBehindOnMajor",
+		min:     "2.0.0",
+		cur:     "2.5.0",
+		latest:  "3.0.0",
+		vAssert: version.CanUpdate,
+	},
+	{
+		desc:    "ReturnsCanUpdateWhenCurrentIsBehindOnMinor",
+		min:     "2.0.0",
+		cur:     "2.0.9",
+		latest:  "2.6.0",
+		vAssert: version.CanUpdate,
+	},
+	{
+		desc:    "ReturnsCanUpdateWhenCurrentIsBehindOnPatch",
+		min:     "2.0.0",
+		cur:     "2.6.0",
+		latest:  "2.6.1",
+		vAssert: version.CanUpdate,
+	},
+	{
+		desc:    "ReturnsIsBeyondWhenCurrentIsAboveLatest",
+		min:     "2.1.0",
+		cur:     "2.6.1",
+		latest:  "2.6.0",
+		vAssert: version.IsBeyond,
+	},
+	{
+		desc:    "ReturnsIsLatestWhenAllVersionsAreEqual",
+		min:     "2.6.0",
+		cur:     "2.6.0",
+		latest:  "2.6.0",
+		vAssert: version.IsLatest,
+	},
+	{
+		desc:    "ReturnsCanUpdateWhenCurrentIsEqualToMinimal",
+		min:     "2.6.0",
+		cur:     "2.6.0",
+		latest:  "2.6.1",
+		vAssert: version.CanUpdate,
+	},
+	{
+		desc:    "TreatsSuffixedVersionsAsOlderThanNonSuffixedVersions",
+		min:     "2.1.0",
+		cur:     "2.6.0-blah",
+		latest:  "2.6.0",
+		vAssert: version.CanUpdate,
+	},
+	{
+		desc:    "OrdersPrereleaseNumbersNumerically",
+		min:     "2.1.0",
+		cur:     "2.7.0-rc.2",
+		latest:  "2.7.0-rc.10",
+		vAssert: version.CanUpdate,
+	},
+	{
+		desc:    "ReturnsIsLatestWhenCurrentIsTheLatestPrerelease",
+		min:     "2.1.0",
+		cur:     "2.7.0-rc.2",
+		latest:  "2.7.0-rc.2",
+		vAssert: version.IsLatest,
+	},
+	{
+		desc:    "ReturnsIsBeyondWhenCurrentIsAPrereleaseAboveLatest",
+		min:     "2.1.0",
+		cur:     "2.7.0-rc.1",
+		latest:  "2.6.0",
+		vAssert: version.IsBeyond,
+	},
+	{
+		desc:    "CanCheckIfLatestWhenMinimalReqIsEmpty(VersionIsLatest)",
+		min:     "",
+		cur:     "2.6.0",
+		latest:  "2.6.0",
+		vAssert: version.IsLatest,
+	},
+	{
+		desc:    "CanCheckIfLatestWhenMinimalReqIsEmpty(VersionCanUpdate)",
+		min:     "",
+		cur:     "0.0.1",
+		latest:  "2.6.0",
+		vAssert: version.CanUpdate,
+	},
+	{
+		desc:    "ReturnsUnknownWhenLatestIsUnknownAndVersionIsAboveMinimal",
+		min:     "1.0.0",
+		cur:     "1.4.0",
+		latest:  "",
+		vAssert: version.IsUnknown,
+	},
+	{
+		desc:    "ReturnsUnknownWhenMinAndLatestAreUnset",
+		min:     "",
+		cur:     "0.0.1",
+		latest:  "",
+		vAssert: version.IsUnknown,
+	},
+}
+
 func TestGithubCheckerCheck(t *testing.T) {
-	testCases := []versionsCaseSpec{
+	for _, tC := range checkCases {
+		t.Run(tC.desc, func(t *testing.T) {
+			var ghErr *github.ErrorResponse
+
+			gc, err := version.NewGithubChecker(context.Background(), newGitHubMock(tC.latest), fakeOrg, fakeRepo, tC.min, tC.cur)
+			if err != nil {
+				if errors.As(err, &ghErr) {
+					t.Fatalf("expected nil error, got %s", ghErr.Message)
+				} else {
+					t.Fatalf("expected nil error, got %s", err)
+				}
+			}
+
+			ans := gc.Check()
+			if ans != tC.vAssert {
+				t.Errorf("expected '%d/%s', got '%d/%s'", tC.vAssert, assertStr(tC.vAssert), ans, assertStr(ans))
+			}
+
+		})
+	}
+}
+
+func TestDownloadLatest(t *testing.T) {
+	var ghErr *github.ErrorResponse
+
+	gc, err := version.NewGithubChecker(context.Background(), newGitHubMock("v3"), fakeOrg, fakeRepo, "v1", "v2")
+	if err != nil {
+		if errors.As(err, &ghErr) {
+			t.Fatalf("expected nil error, got %s", ghErr.Message)
+		} else {
+			t.Fatalf("expected nil error, got %s", err)
+		}
+	}
+
+	asset, err := gc.DownloadLatest(context.Background())
+	if err != nil {
+		t.Fatalf("expected nil error on download, got %s", err)
+	}
+
+	verifyDownloadedFile(t, asset)
+}
+
+// verifyDownloadedFile checks that the downloaded asset has the fake asset content
+func verifyDownloadedFile(t *testing.T, asset *version.Asset) {
+
+	t.Helper()
+
+	fd, err := os.Open(asset.Filename)
+	if err != nil {
+		t.Fatalf("error opening downloaded file: %s", err)
+	}
+	defer fd.Close()
+
+	text, err := ioutil.ReadAll(fd)
+	if err != nil {
+		t.Fatalf("error reading downloaded file: %s", err)
+	}
+
+	// For some reason the mock returns the fake content wrapped in "",
+	// so we use a contains match. That's good enough for this.
+	if !strings.Contains(string(text), fakeAssetContent) {
+		t.Errorf("== expected file content:\n%s\n== got:\n%s\n", fakeAssetContent, string(text))
+	}
+
+}
+
+func TestNewGithubChannelChecker(t *testing.T) {
+	tags := []string{"v2.6.0", "v2.7.0-nightly.20221130", "v2.7.0-rc.1", "v2.5.0", "not-a-version", "v2.8.0-nightly.20221201"}
+	drafts := []string{"v3.0.0"}
+
+	testCases := []struct {
+		desc    string
+		channel version.Channel
+		tags    []string
+		latest  string
+		vAssert version.Assertion
+	}{
 		{
-			desc:    "ReturnsLatestWhenCurrentEqualsLatest",
-			min:     "2.1.0",
-			cur:     "2.6.0",
+			desc:    "StableUsesLatestRelease",
+			channel: version.ChannelStable,
+			tags:    tags,
 			latest:  "2.6.0",
 			vAssert: version.IsLatest,
 		},
 		{
-			desc:    "ReturnsMustUpdateWhenCurrentIsBellowMinimal",
-			min:     "2.1.0",
-			cur:     "2.0.9",
-			latest:  "2.6.0",
-			vAssert: version.MustUpdate,
+			desc:    "BetaIncludesPrereleasesButNotNightlies",
+			channel: version.ChannelBeta,
+			tags:    tags,
+			latest:  "2.7.0-rc.1",
+			vAssert: version.CanUpdate,
 		},
 		{
-			desc:    "ReturnsMustUpdateWhenCurrentIsBellowMinimalEvenIfLatestIsUnknown",
-			min:     "1.0.0",
-			cur:     "0.4.0",
+			desc:    "NightlyIncludesAllReleases",
+			channel: version.ChannelNightly,
+			tags:    tags,
+			latest:  "2.8.0-nightly.20221201",
+			vAssert: version.CanUpdate,
+		},
+		{
+			desc:    "LatestIsUnknownWhenChannelHasNoReleases",
+			channel: version.ChannelBeta,
+			tags:    []string{"v2.8.0-nightly.20221201"},
 			latest:  "",
-			vAssert: version.MustUpdate,
+			vAssert: version.IsUnknown,
 		},
+	}
+	for _, tC := range testCases {
+		t.Run(tC.desc, func(t *testing.T) {
+			gc, err := version.NewGithubChannelChecker(context.Background(), newChannelsGitHubMock("v2.6.0", tC.tags, drafts),
+				fakeOrg, fakeRepo, tC.channel, version.Policy{MinimalRequired: "2.0.0"}, "2.6.0")
+			if err != nil {
+				t.Fatalf("expected nil error, got %s", err)
+			}
+
+			verifyExpectedVersions(t, gc, versionsCaseSpec{min: "2.0.0", cur: "2.6.0", latest: tC.latest})
+
+			ans := gc.Check()
+			if ans != tC.vAssert {
+				t.Errorf("expected '%d/%s', got '%d/%s'", tC.vAssert, assertStr(tC.vAssert), ans, assertStr(ans))
+			}
+		})
+	}
+}
+
+func TestGithubCheckerDownloadVersion(t *testing.T) {
+	gc, err := version.NewGithubChecker(context.Background(), newPolicyGitHubMock("v2.6.0", []string{"v2.4.0", "2.5.0"}),
+		fakeOrg, fakeRepo, "2.0.0", "2.6.0")
+	if err != nil {
+		t.Fatalf("expected nil error, got %s", err)
+	}
+
+	// tags with and without the v prefix are found
+	for _, v := range []string{"2.4.0", "v2.5.0"} {
+		asset, err := gc.DownloadVersion(context.Background(), v)
+		if err != nil {
+			t.Fatalf("expected nil error downloading %s, got %s", v, err)
+		}
+
+		verifyDownloadedFile(t, asset)
+	}
+
+	_, err = gc.DownloadVersion(context.Background(), "2.3.0")
+	if err == nil {
+		t.Errorf("expected error downloading missing version, got nil")
+	}
+}
+
+// newAssetsGitHubMock simulates a scenario where repo has latest release set to
+// latestV, with the given asset names
+func newAssetsGitHubMock(latestV string, names []string) *github.Client {
+	return newChecksumsGitHubMock(latestV, names, fakeChecksums(names))
+}
+
+// fakeChecksums returns a checksum manifest with the checksum of fakeAssetContent for
+// each of names that is not a checksum manifest itself
+func fakeChecksums(names []string) string {
+	var sb strings.Builder
+	for _, name := range names {
+		if name != "checksums.txt" {
+			fmt.Fprintf(&sb, "%x  %s\n", sha256.Sum256([]byte(fakeAssetContent)), name)
+		}
+	}
+
+	return sb.String()
+}
+
+// newChecksumsGitHubMock simulates a repo with latest release set to latestV, with
+// assets names. Assets named checksums.txt have the checksums content, all others have
+// fakeAssetContent.
+func newChecksumsGitHubMock(latestV string, names []string, checksums string) *github.Client {
+	release := github.RepositoryRelease{TagName: strp(latestV)}
+	for i, name := range names {
+		release.Assets = append(release.Assets, &github.ReleaseAsset{ID: int64p(int64(100 + i)), Name: strp(name)})
+	}
+
+	m := mock.NewMockedHTTPClient(
+		mock.WithRequestMatch(
+			mock.GetReposReleasesLatestByOwnerByRepo,
+			release,
+		),
+		mock.WithRequestMatchHandler(
+			mock.GetReposReleasesAssetsByOwnerByRepoByAssetId,
+			http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				for _, a := range release.Assets {
+					if path.Base(r.URL.Path) == fmt.Sprint(a.GetID()) && a.GetName() == "checksums.txt" {
+						fmt.Fprint(w, checksums)
+						return
+					}
+				}
+				fmt.Fprint(w, fakeAssetContent)
+			}),
+		),
+	)
+
+	return github.NewClient(m)
+}
+
+func TestGithubCheckerPicksPlatformAsset(t *testing.T) {
+	otherArch := "arm64"
+	if runtime.GOARCH == otherArch {
+		otherArch = "amd64"
+	}
+	archAlias := map[string]string{"amd64": "x86_64", "arm64": "aarch64"}[runtime.GOARCH]
+
+	type testCase struct {
+		desc       string
+		pattern    string
+		names      []string
+		expected   string
+		shouldFail bool
+	}
+	testCases := []testCase{
 		{
-			desc:    "ReturnsCanUpdateWhenCurrentIsBehindOnMajor",
-			min:     "2.0.0",
+			desc: "MatchesOSAndArch",
+			names: []string{
+				fmt.Sprintf("cli-v3.0.0-%s-%s.tar.gz", runtime.GOOS, otherArch),
+				fmt.Sprintf("cli-v3.0.0-%s-%s.tar.gz", runtime.GOOS, runtime.GOARCH),
+				"cli-v3.0.0-plan9-386.tar.gz",
+			},
+			expected: fmt.Sprintf("cli-v3.0.0-%s-%s.tar.gz", runtime.GOOS, runtime.GOARCH),
+		},
+		{
+			desc: "IgnoresChecksumFiles",
+			names: []string{
+				fmt.Sprintf("cli-v3.0.0-%s-%s.tar.gz", runtime.GOOS, runtime.GOARCH),
+				fmt.Sprintf("cli-v3.0.0-%s-%s.tar.gz.sha256", runtime.GOOS, runtime.GOARCH),
+				"checksums.txt",
+			},
+			expected: fmt.Sprintf("cli-v3.0.0-%s-%s.tar.gz", runtime.GOOS, runtime.GOARCH),
+		},
+		{
+			desc:     "WorksWithCustomPattern",
+			pattern:  `{name}_{version}_{os}_{arch}\.{ext}`,
+			names:    []string{fmt.Sprintf("cli_3.0.0_%s_%s.zip", strings.ToUpper(runtime.GOOS), runtime.GOARCH)},
+			expected: fmt.Sprintf("cli_3.0.0_%s_%s.zip", strings.ToUpper(runtime.GOOS), runtime.GOARCH),
+		},
+		{
+			desc:       "FailsWithoutMatches",
+			names:      []string{fmt.Sprintf("cli-v3.0.0-%s-%s.tar.gz", runtime.GOOS, otherArch)},
+			shouldFail: true,
+		},
+		{
+			desc:       "FailsWithAssetOfAnotherVersion",
+			names:      []string{fmt.Sprintf("cli-v2.9.0-%s-%s.tar.gz", runtime.GOOS, runtime.GOARCH)},
+			shouldFail: true,
+		},
+		{
+			desc: "FailsWithAmbiguousMatches",
+			names: []string{
+				fmt.Sprintf("cli-v3.0.0-%s-%s.tar.gz", runtime.GOOS, runtime.GOARCH),
+				fmt.Sprintf("cli-v3.0.0-%s-%s.zip", runtime.GOOS, runtime.GOARCH),
+			},
+			shouldFail: true,
+		},
+	}
+	if archAlias != "" {
+		testCases = append(testCases, testCase{
+			desc:     "MatchesArchAliases",
+			names:    []string{fmt.Sprintf("cli-3.0.0-%s-%s.tar.gz", runtime.GOOS, archAlias)},
+			expected: fmt.Sprintf("cli-3.0.0-%s-%s.tar.gz", runtime.GOOS, archAlias),
+		})
+	}
+	for _, tC := range testCases {
+		t.Run(tC.desc, func(t *testing.T) {
+			gc, err := version.NewGithubChannelChecker(context.Background(), newAssetsGitHubMock("v3.0.0", tC.names),
+				fakeOrg, fakeRepo, version.ChannelStable, version.Policy{AssetPattern: tC.pattern}, "v2.0.0")
+			if err != nil {
+				t.Fatalf("expected nil error, got %s", err)
+			}
+
+			asset, err := gc.DownloadLatest(context.Background())
+			if tC.shouldFail {
+				if err == nil {
+					t.Fatalf("expected error on download, got nil (downloaded %s)", asset.Filename)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("expected nil error on download, got %s", err)
+			}
+
+			if !strings.HasSuffix(asset.Filename, tC.expected) {
+				t.Errorf("expected asset %s to be downloaded, got %s", tC.expected, asset.Filename)
+			}
+		})
+	}
+}
+
+func TestNewGithubCheckerFailsWithInvalidAssetPattern(t *testing.T) {
+	_, err := version.NewGithubChannelChecker(context.Background(), newAssetsGitHubMock("v3.0.0", nil),
+		fakeOrg, fakeRepo, version.ChannelStable, version.Policy{AssetPattern: "{name}-(unclosed"}, "2.5.0")
+	if err == nil {
+		t.Errorf("expected error, got nil")
+	}
+}
+
+func TestNewGithubCheckerWithPolicy(t *testing.T) {
+	tags := []string{"v2.4.0", "v2.5.0", "v2.6.0"}
+
+	testCases := []struct {
+		desc    string
+		policy  version.Policy
+		cur     string
+		latest  string
+		vAssert version.Assertion
+	}{
+		{
+			desc:    "PinnedVersionIsLatestEvenIfOlder",
+			policy:  version.Policy{Pinned: "2.4.0"},
 			cur:     "2.5.0",
-			latest:  "3.0.0",
-			vAssert: version.CanUpdate,
+			latest:  "2.4.0",
+			vAssert: version.MustDowngrade,
 		},
 		{
-			desc:    "ReturnsCanUpdateWhenCurrentIsBehindOnMinor",
-			min:     "2.0.0",
-			cur:     "2.0.9",
-			latest:  "2.6.0",
+			desc:    "MustUpdateWhenBelowPinnedVersion",
+			policy:  version.Policy{Pinned: "v2.5.0"},
+			cur:     "2.4.0",
+			latest:  "2.5.0",
+			vAssert: version.MustUpdate,
+		},
+		{
+			desc:    "IsLatestWhenAtPinnedVersion",
+			policy:  version.Policy{MinimalRequired: "2.5.0", Pinned: "2.4.0"},
+			cur:     "2.4.0",
+			latest:  "2.4.0",
+			vAssert: version.IsLatest,
+		},
+		{
+			desc:    "MaximalAllowedCapsLatest",
+			policy:  version.Policy{MaximalAllowed: "2.5.0"},
+			cur:     "2.4.0",
+			latest:  "2.5.0",
 			vAssert: version.CanUpdate,
 		},
 		{
-			desc:    "ReturnsCanUpdateWhenCurrentIsBehindOnPatch",
-			min:     "2.0.0",
+			desc:    "MustDowngradeWhenAboveMaximalAllowed",
+			policy:  version.Policy{MaximalAllowed: "2.5.0"},
 			cur:     "2.6.0",
-			latest:  "2.6.1",
-			vAssert: version.CanUpdate,
+			latest:  "2.5.0",
+			vAssert: version.MustDowngrade,
 		},
 		{
-			desc:    "ReturnsIsBeyondWhenCurrentIsAboveLatest",
-			min:     "2.1.0",
-			cur:     "2.6.1",
+			desc:    "MaximalAllowedAboveLatestIsIgnored",
+			policy:  version.Policy{MaximalAllowed: "3.0.0"},
+			cur:     "2.5.0",
 			latest:  "2.6.0",
-			vAssert: version.IsBeyond,
+			vAssert: version.CanUpdate,
 		},
 		{
-			desc:    "ReturnsIsLatestWhenAllVersionsAreEqual",
-			min:     "2.6.0",
-			cur:     "2.6.0",
-			latest:  "2.6.0",
-			vAssert: version.IsLatest,
+			desc:    "MustDowngradeEvenIfPinnedReleaseIsMissing",
+			policy:  version.Policy{Pinned: "2.3.0"},
+			cur:     "2.5.0",
+			latest:  "",
+			vAssert: version.MustDowngrade,
 		},
 		{
-			desc:    "ReturnsCanUpdateWhenCurrentIsEqualToMinimal",
-			min:     "2.6.0",
-			cur:     "2.6.0",
-			latest:  "2.6.1",
-			vAssert: version.CanUpdate,
+			desc:    "IsRevokedWhenCurrentIsRevoked",
+			policy:  version.Policy{MinimalRequired: "2.0.0", Revoked: []string{"2.3.0", "v2.4.0"}},
+			cur:     "2.4.0",
+			latest:  "2.6.0",
+			vAssert: version.IsRevoked,
 		},
 		{
-			desc:    "TreatsSuffixedVersionsAsOlderThanNonSuffixedVersions",
-			min:     "2.1.0",
-			cur:     "2.6.0-blah",
+			desc:    "IsRevokedWhenCurrentIsInRevokedRange",
+			policy:  version.Policy{Revoked: []string{">= 2.4.0, < 2.4.3"}},
+			cur:     "2.4.2",
 			latest:  "2.6.0",
-			vAssert: version.CanUpdate,
+			vAssert: version.IsRevoked,
 		},
 		{
-			desc:    "CanCheckIfLatestWhenMinimalReqIsEmpty(VersionIsLatest)",
-			min:     "",
+			desc:    "IsRevokedEvenIfLatest",
+			policy:  version.Policy{Revoked: []string{"2.6.0"}},
 			cur:     "2.6.0",
-			latest:  "2.6.0",
+			latest:  "2.5.0",
+			vAssert: version.IsRevoked,
+		},
+		{
+			desc:    "RevokedLatestIsNotOffered",
+			policy:  version.Policy{Revoked: []string{"2.6.0"}},
+			cur:     "2.5.0",
+			latest:  "2.5.0",
 			vAssert: version.IsLatest,
 		},
 		{
-			desc:    "CanCheckIfLatestWhenMinimalReqIsEmpty(VersionCanUpdate)",
-			min:     "",
-			cur:     "0.0.1",
-			latest:  "2.6.0",
+			desc:    "CanUpdateToNewestReleaseBelowRevokedLatest",
+			policy:  version.Policy{Revoked: []string{">= 2.5.1"}},
+			cur:     "2.4.0",
+			latest:  "2.5.0",
 			vAssert: version.CanUpdate,
 		},
 		{
-			desc:    "ReturnsUnknownWhenLatestIsUnknownAndVersionIsAboveMinimal",
-			min:     "1.0.0",
-			cur:     "1.4.0",
-			latest:  "",
-			vAssert: version.IsUnknown,
+			desc:    "RevokedMaximalAllowedIsNotOffered",
+			policy:  version.Policy{MaximalAllowed: "2.5.0", Revoked: []string{"2.5.0"}},
+			cur:     "2.6.0",
+			latest:  "2.4.0",
+			vAssert: version.MustDowngrade,
 		},
 		{
-			desc:    "ReturnsUnknownWhenMinAndLatestAreUnset",
-			min:     "",
-			cur:     "0.0.1",
+			desc:    "IsUnknownWhenEveryReleaseIsRevoked",
+			policy:  version.Policy{Revoked: []string{">= 2.4.0"}},
+			cur:     "2.3.0",
 			latest:  "",
 			vAssert: version.IsUnknown,
 		},
+		{
+			desc:    "CanUpdateWhenOutsideRevokedRange",
+			policy:  version.Policy{Revoked: []string{">= 2.4.0, < 2.4.3"}},
+			cur:     "2.4.3",
+			latest:  "2.6.0",
+			vAssert: version.CanUpdate,
+		},
 	}
 	for _, tC := range testCases {
 		t.Run(tC.desc, func(t *testing.T) {
-			var ghErr *github.ErrorResponse
-
-			gc, err := version.NewGithubChecker(newGitHubMock(tC.latest), fakeOrg, fakeRepo, tC.min, tC.cur)
+			gc, err := version.NewGithubChannelChecker(context.Background(), newPolicyGitHubMock("v2.6.0", tags),
+				fakeOrg, fakeRepo, version.ChannelStable, tC.policy, tC.cur)
 			if err != nil {
-				if errors.As(err, &ghErr) {
-					t.Fatalf("expected nil error, got %s", ghErr.Message)
-				} else {
-					t.Fatalf("expected nil error, got %s", err)
-				}
+				t.Fatalf("expected nil error, got %s", err)
+			}
+
+			if gc.Latest() != tC.latest {
+				t.Errorf("expected latest version to be %s, got %s", tC.latest, gc.Latest())
 			}
 
 			ans := gc.Check()
 			if ans != tC.vAssert {
 				t.Errorf("expected '%d/%s', got '%d/%s'", tC.vAssert, assertStr(tC.vAssert), ans, assertStr(ans))
 			}
+		})
+	}
+}
+
+func TestNewGithubCheckerFailsWithInvalidRevokedVersion(t *testing.T) {
+	_, err := version.NewGithubChannelChecker(context.Background(), newPolicyGitHubMock("v2.6.0", nil),
+		fakeOrg, fakeRepo, version.ChannelStable, version.Policy{Revoked: []string{"~> banana"}}, "2.5.0")
+	if err == nil {
+		t.Errorf("expected error, got nil")
+	}
+}
 
+func TestParseChannel(t *testing.T) {
+	testCases := []struct {
+		desc       string
+		name       string
+		channel    version.Channel
+		shouldFail bool
+	}{
+		{desc: "BlankIsStable", name: "", channel: version.ChannelStable},
+		{desc: "IgnoresCase", name: "Beta", channel: version.ChannelBeta},
+		{desc: "Nightly", name: "nightly", channel: version.ChannelNightly},
+		{desc: "FailsWithUnknownChannel", name: "edge", shouldFail: true},
+	}
+	for _, tC := range testCases {
+		t.Run(tC.desc, func(t *testing.T) {
+			ch, err := version.ParseChannel(tC.name)
+			if tC.shouldFail {
+				if err == nil {
+					t.Fatal("expected error, got nil")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("expected nil error, got %s", err)
+			}
+			if ch != tC.channel {
+				t.Errorf("expected channel %s, got %s", tC.channel, ch)
+			}
 		})
 	}
 }
 
-func TestDownloadLatest(t *testing.T) {
-	var ghErr *github.ErrorResponse
+func TestGithubCheckerVerifiesChecksums(t *testing.T) {
+	archive := fakeAssetName("v3.0.0", runtime.GOOS)
+	binary := strings.TrimSuffix(strings.TrimSuffix(archive, ".tar.gz"), ".zip")
+	sum := sha256.Sum256([]byte(fakeAssetContent))
+	binarySum := sha256.Sum256([]byte("the binary"))
 
-	gc, err := version.NewGithubChecker(newGitHubMock("v3"), fakeOrg, fakeRepo, "v1", "v2")
-	if err != nil {
-		if errors.As(err, &ghErr) {
-			t.Fatalf("expected nil error, got %s", ghErr.Message)
-		} else {
-			t.Fatalf("expected nil error, got %s", err)
+	testCases := []struct {
+		desc         string
+		checksums    string
+		binarySHA256 []byte
+		shouldFail   bool
+	}{
+		{
+			desc:         "WithGNUStyleManifest",
+			checksums:    fmt.Sprintf("%x  %s\n%x *%s\n", sum, archive, binarySum, binary),
+			binarySHA256: binarySum[:],
+		},
+		{
+			desc:      "WithBSDStyleManifest",
+			checksums: fmt.Sprintf("SHA256 (%s) = %x\n", archive, sum),
+		},
+		{
+			desc:       "FailsWithWrongChecksum",
+			checksums:  fmt.Sprintf("%x  %s\n", binarySum, archive),
+			shouldFail: true,
+		},
+		{
+			desc:       "FailsWithoutArchiveChecksum",
+			checksums:  fmt.Sprintf("%x  %s\n", sum, "some-other-file.tar.gz"),
+			shouldFail: true,
+		},
+		{
+			desc:       "FailsWithInvalidManifest",
+			checksums:  "not a checksum manifest\n",
+			shouldFail: true,
+		},
+	}
+	for _, tC := range testCases {
+		t.Run(tC.desc, func(t *testing.T) {
+			gc, err := version.NewGithubChannelChecker(context.Background(), newChecksumsGitHubMock("v3.0.0", []string{archive, "checksums.txt"}, tC.checksums),
+				fakeOrg, fakeRepo, version.ChannelStable, version.Policy{}, "v2.0.0")
+			if err != nil {
+				t.Fatalf("expected nil error, got %s", err)
+			}
+
+			asset, err := gc.DownloadLatest(context.Background())
+			if tC.shouldFail {
+				if err == nil {
+					t.Fatalf("expected error on download, got nil (downloaded %s)", asset.Filename)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("expected nil error on download, got %s", err)
+			}
+
+			if !bytes.Equal(asset.SHA256, sum[:]) {
+				t.Errorf("expected asset checksum %x, got %x", sum, asset.SHA256)
+			}
+			if !bytes.Equal(asset.BinarySHA256, tC.binarySHA256) {
+				t.Errorf("expected binary checksum %x, got %x", tC.binarySHA256, asset.BinarySHA256)
+			}
+		})
+	}
+}
+
+func TestGithubCheckerDownloadsSignature(t *testing.T) {
+	archive := fakeAssetName("v3.0.0", runtime.GOOS)
+	signature := strings.TrimSuffix(strings.TrimSuffix(archive, ".tar.gz"), ".zip") + ".minisig"
+
+	testCases := []struct {
+		desc     string
+		names    []string
+		expected string
+	}{
+		{
+			desc:     "WithSignature",
+			names:    []string{archive, signature, "other-binary.minisig"},
+			expected: signature,
+		},
+		{
+			desc:  "WithoutSignature",
+			names: []string{archive, "other-binary.minisig"},
+		},
+	}
+	for _, tC := range testCases {
+		t.Run(tC.desc, func(t *testing.T) {
+			gc, err := version.NewGithubChannelChecker(context.Background(), newAssetsGitHubMock("v3.0.0", tC.names),
+				fakeOrg, fakeRepo, version.ChannelStable, version.Policy{}, "v2.0.0")
+			if err != nil {
+				t.Fatalf("expected nil error, got %s", err)
+			}
+
+			asset, err := gc.DownloadLatest(context.Background())
+			if err != nil {
+				t.Fatalf("expected nil error on download, got %s", err)
+			}
+
+			if tC.expected == "" {
+				if asset.Signature != "" {
+					t.Errorf("expected no signature, got %s", asset.Signature)
+				}
+				return
+			}
+			if !strings.HasSuffix(asset.Signature, tC.expected) {
+				t.Errorf("expected signature %s to be downloaded, got %q", tC.expected, asset.Signature)
+			}
+			if _, err := os.Stat(asset.Signature); err != nil {
+				t.Errorf("expected downloaded signature, got %s", err)
+			}
+		})
+	}
+}
+
+func TestGithubCheckerDownloadPatch(t *testing.T) {
+	archive := fakeAssetName("v3.0.0", runtime.GOOS)
+	binary := strings.TrimSuffix(strings.TrimSuffix(archive, ".tar.gz"), ".zip")
+	patch := binary + ".from-v2.0.0.bsdiff"
+	sum := sha256.Sum256([]byte(fakeAssetContent))
+	withBinary := fakeChecksums([]string{archive, patch}) + fmt.Sprintf("%x  %s\n", sum, binary)
+
+	testCases := []struct {
+		desc      string
+		names     []string
+		checksums string
+		noPatch   bool
+	}{
+		{
+			desc:      "WithPatchFromCurrentVersion",
+			names:     []string{archive, patch, binary + ".from-v1.0.0.bsdiff", "checksums.txt"},
+			checksums: withBinary,
+		},
+		{
+			desc:      "WithoutPatchFromCurrentVersion",
+			names:     []string{archive, binary + ".from-v1.0.0.bsdiff", "checksums.txt"},
+			checksums: withBinary,
+			noPatch:   true,
+		},
+		{
+			desc:      "WithoutBinaryChecksum",
+			names:     []string{archive, patch, "checksums.txt"},
+			checksums: fakeChecksums([]string{archive, patch}),
+			noPatch:   true,
+		},
+		{
+			desc:    "WithoutChecksumManifest",
+			names:   []string{archive, patch},
+			noPatch: true,
+		},
+	}
+	for _, tC := range testCases {
+		t.Run(tC.desc, func(t *testing.T) {
+			gc, err := version.NewGithubChannelChecker(context.Background(), newChecksumsGitHubMock("v3.0.0", tC.names, tC.checksums),
+				fakeOrg, fakeRepo, version.ChannelStable, version.Policy{}, "v2.0.0")
+			if err != nil {
+				t.Fatalf("expected nil error, got %s", err)
+			}
+
+			asset, err := gc.DownloadPatch(context.Background(), gc.Latest())
+			if tC.noPatch {
+				if !errors.Is(err, version.ErrNoPatch) {
+					t.Fatalf("expected ErrNoPatch, got %v", err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("expected nil error on download, got %s", err)
+			}
+
+			if !asset.Patch || !strings.HasSuffix(asset.Filename, patch) {
+				t.Errorf("expected patch %s to be downloaded, got %+v", patch, asset)
+			}
+			if !bytes.Equal(asset.BinarySHA256, sum[:]) {
+				t.Errorf("expected binary checksum %x, got %x", sum, asset.BinarySHA256)
+			}
+		})
+	}
+}
+
+const fakeGitHubToken = "ghp_not-really-a-token"
+
+// newRedirectingGitHubServer starts a local stand-in for a GitHub Enterprise API, serving
+// a private fakeOrg/fakeRepo with latest release set to latestV. It redirects asset
+// downloads to its own storage, which redirects them to a CDN on another host (returned
+// too), as GitHub does.
+//
+// The API and its storage answer 404 to requests without the fake token, as GitHub does
+// for private repos. The CDN refuses requests with any token, as S3 does.
+func newRedirectingGitHubServer(t *testing.T, latestV string) (*httptest.Server, *httptest.Server) {
+	t.Helper()
+
+	cdn := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") != "" {
+			http.Error(w, "only one auth mechanism allowed", http.StatusBadRequest)
+			return
 		}
+		http.ServeContent(w, r, path.Base(r.URL.Path), time.Time{}, strings.NewReader(fakeAssetContent))
+	}))
+	t.Cleanup(cdn.Close)
+
+	release := fakeGitHubRelease(latestV)
+	for i, a := range release.Assets {
+		a.ID = int64p(int64(100 + i))
+	}
+
+	var api *httptest.Server
+	api = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") != "Bearer "+fakeGitHubToken {
+			http.NotFound(w, r)
+			return
+		}
+
+		repoPath := fmt.Sprintf("/api/v3/repos/%s/%s/releases/", fakeOrg, fakeRepo)
+		switch {
+		case r.URL.Path == repoPath+"latest":
+			w.Write(mock.MustMarshal(release))
+
+		case strings.HasPrefix(r.URL.Path, repoPath+"assets/"):
+			http.Redirect(w, r, "/storage/"+path.Base(r.URL.Path), http.StatusFound)
+
+		case strings.HasPrefix(r.URL.Path, "/storage/"):
+			for _, a := range release.Assets {
+				if path.Base(r.URL.Path) == fmt.Sprint(a.GetID()) {
+					http.Redirect(w, r, cdn.URL+"/"+a.GetName()+"?signed=yes", http.StatusTemporaryRedirect)
+					return
+				}
+			}
+			http.NotFound(w, r)
+
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	t.Cleanup(api.Close)
+
+	return api, cdn
+}
+
+// newEnterpriseClient returns a client of the GitHub Enterprise API at baseURL,
+// authenticated with token if it is not blank
+func newEnterpriseClient(t *testing.T, baseURL string, token string) *github.Client {
+	t.Helper()
+
+	t.Setenv("GITHUB_TOKEN", token)
+	client, err := gh.NewClient(nil)
+	if err != nil {
+		t.Fatalf("expected nil error creating client, got %s", err)
+	}
+	client.BaseURL, err = url.Parse(baseURL + "/api/v3/")
+	if err != nil {
+		t.Fatalf("expected nil error parsing base url, got %s", err)
+	}
+
+	return client
+}
+
+func TestGithubCheckerFollowsAssetRedirectsFromPrivateRepo(t *testing.T) {
+	api, _ := newRedirectingGitHubServer(t, "v3")
+
+	gc, err := version.NewGithubChecker(context.Background(), newEnterpriseClient(t, api.URL, fakeGitHubToken), fakeOrg, fakeRepo, "v1", "v2")
+	if err != nil {
+		t.Fatalf("expected nil error, got %s", err)
 	}
 
-	filename, err := gc.DownloadLatest()
+	asset, err := gc.DownloadLatest(context.Background())
 	if err != nil {
 		t.Fatalf("expected nil error on download, got %s", err)
 	}
 
-	fd, err := os.Open(filename)
+	verifyDownloadedFile(t, asset)
+}
+
+func TestGithubCheckerFailsOnAssetRedirectLoop(t *testing.T) {
+	release := fakeGitHubRelease("v3")
+	var srv *httptest.Server
+	srv = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch {
+		case strings.HasSuffix(r.URL.Path, "/releases/latest"):
+			w.Write(mock.MustMarshal(release))
+		default:
+			http.Redirect(w, r, srv.URL+"/loop", http.StatusFound)
+		}
+	}))
+	t.Cleanup(srv.Close)
+
+	gc, err := version.NewGithubChecker(context.Background(), newEnterpriseClient(t, srv.URL, ""), fakeOrg, fakeRepo, "v1", "v2")
 	if err != nil {
-		t.Fatalf("error opening downloaded file: %s", err)
+		t.Fatalf("expected nil error, got %s", err)
 	}
-	defer fd.Close()
 
-	text, err := ioutil.ReadAll(fd)
+	_, err = gc.DownloadLatest(context.Background())
+	if err == nil || !strings.Contains(err.Error(), "redirects") {
+		t.Errorf("expected error about too many redirects, got %v", err)
+	}
+}
+
+// newLatestGitHubMock simulates a repo whose latest release lookups are answered by h
+func newLatestGitHubMock(h http.HandlerFunc) *github.Client {
+	return github.NewClient(mock.NewMockedHTTPClient(
+		mock.WithRequestMatchHandler(mock.GetReposReleasesLatestByOwnerByRepo, h),
+	))
+}
+
+func TestGithubCheckerReportsRateLimits(t *testing.T) {
+	reset := time.Now().Add(17 * time.Minute).Truncate(time.Second)
+
+	testCases := []struct {
+		desc      string
+		header    map[string]string
+		body      string
+		expReset  time.Time
+		expReason string
+	}{
+		{
+			desc: "PrimaryRateLimit",
+			header: map[string]string{
+				"X-RateLimit-Limit":     "60",
+				"X-RateLimit-Remaining": "0",
+				"X-RateLimit-Reset":     fmt.Sprint(reset.Unix()),
+			},
+			body:      `{"message": "API rate limit exceeded for 127.0.0.1."}`,
+			expReset:  reset,
+			expReason: "resets at " + reset.Local().Format("15:04:05"),
+		},
+		{
+			desc:      "SecondaryRateLimit",
+			header:    map[string]string{"Retry-After": "120"},
+			body:      `{"message": "You have exceeded a secondary rate limit.", "documentation_url": "https://docs.github.com/en/rest/overview/resources-in-the-rest-api#secondary-rate-limits"}`,
+			expReason: "try again in 2m0s",
+		},
+	}
+	for _, tC := range testCases {
+		t.Run(tC.desc, func(t *testing.T) {
+			requests := 0
+			client := newLatestGitHubMock(func(w http.ResponseWriter, r *http.Request) {
+				requests++
+				for k, v := range tC.header {
+					w.Header().Set(k, v)
+				}
+				w.WriteHeader(http.StatusForbidden)
+				fmt.Fprint(w, tC.body)
+			})
+
+			gc, err := version.NewGithubChecker(context.Background(), client, fakeOrg, fakeRepo, "v1", "v2")
+			if err != nil {
+				t.Fatalf("expected nil error, got %s", err)
+			}
+
+			if ans := gc.Check(); ans != version.IsUnknown {
+				t.Errorf("expected '%d/%s', got '%d/%s'", version.IsUnknown, assertStr(version.IsUnknown), ans, assertStr(ans))
+			}
+			if requests != 1 {
+				t.Errorf("expected rate limited lookup not to be retried, got %d requests", requests)
+			}
+
+			var rateErr *version.RateLimitedError
+			if !errors.As(gc.LatestErr(), &rateErr) {
+				t.Fatalf("expected latest version error matching RateLimitedError, got %v", gc.LatestErr())
+			}
+			if !tC.expReset.IsZero() && !rateErr.Reset.Equal(tC.expReset) {
+				t.Errorf("expected rate limit reset at %s, got %s", tC.expReset, rateErr.Reset)
+			}
+			if !strings.Contains(rateErr.Error(), tC.expReason) {
+				t.Errorf("expected error telling %q, got %q", tC.expReason, rateErr.Error())
+			}
+		})
+	}
+}
+
+func TestGithubCheckerRetriesTransientErrors(t *testing.T) {
+	defer version.SetAPIRetryDelay(version.SetAPIRetryDelay(time.Millisecond))
+
+	testCases := []struct {
+		desc        string
+		failures    int
+		status      int
+		expRequests int
+		expLatest   string
+	}{
+		{
+			desc:        "RecoversFromServerErrors",
+			failures:    2,
+			status:      http.StatusBadGateway,
+			expRequests: 3,
+			expLatest:   "3.0.0",
+		},
+		{
+			desc:        "GivesUpOnPersistentServerErrors",
+			failures:    10,
+			status:      http.StatusServiceUnavailable,
+			expRequests: 4,
+		},
+		{
+			desc:        "DoesNotRetryClientErrors",
+			failures:    10,
+			status:      http.StatusNotFound,
+			expRequests: 1,
+		},
+	}
+	for _, tC := range testCases {
+		t.Run(tC.desc, func(t *testing.T) {
+			requests := 0
+			client := newLatestGitHubMock(func(w http.ResponseWriter, r *http.Request) {
+				requests++
+				if requests <= tC.failures {
+					// not mock.WriteError: it sends a null Response field, which
+					// overrides the real one in the decoded github.ErrorResponse
+					w.WriteHeader(tC.status)
+					fmt.Fprintf(w, `{"message": %q}`, http.StatusText(tC.status))
+					return
+				}
+				w.Write(mock.MustMarshal(fakeGitHubRelease("v3")))
+			})
+
+			gc, err := version.NewGithubChecker(context.Background(), client, fakeOrg, fakeRepo, "v1", "v2")
+			if err != nil {
+				t.Fatalf("expected nil error, got %s", err)
+			}
+
+			if requests != tC.expRequests {
+				t.Errorf("expected %d requests, got %d", tC.expRequests, requests)
+			}
+			if gc.Latest() != tC.expLatest {
+				t.Errorf("expected latest version %q, got %q", tC.expLatest, gc.Latest())
+			}
+		})
+	}
+}
+
+// newChangelogGitHubMock simulates a repo whose latest release is v2.4.0, listing its
+// releases (with notes) in pages of two
+func newChangelogGitHubMock() *github.Client {
+	release := func(tag string, draft bool) github.RepositoryRelease {
+		return github.RepositoryRelease{
+			TagName: strp(tag),
+			Name:    strp("Release " + tag),
+			Body:    strp("Notes for " + tag),
+			HTMLURL: strp("https://github.com/" + fakeOrg + "/" + fakeRepo + "/releases/tag/" + tag),
+			Draft:   &draft,
+		}
+	}
+
+	m := mock.NewMockedHTTPClient(
+		mock.WithRequestMatch(
+			mock.GetReposReleasesLatestByOwnerByRepo,
+			github.RepositoryRelease{TagName: strp("v2.4.0")},
+		),
+		mock.WithRequestMatchPages(
+			mock.GetReposReleasesByOwnerByRepo,
+			[]github.RepositoryRelease{release("v3.0.0", true), release("v2.5.0-rc.1", false)},
+			[]github.RepositoryRelease{release("v2.4.0", false), release("v2.3.0", false)},
+			[]github.RepositoryRelease{release("v2.2.0", false), release("v2.1.0", false)},
+			[]github.RepositoryRelease{release("v2.0.0", false), release("v1.9.0", false)},
+		),
+	)
+
+	return github.NewClient(m)
+}
+
+func TestGithubCheckerChangelog(t *testing.T) {
+	testCases := []struct {
+		desc          string
+		min           string
+		cur           string
+		expVersions   []string
+		expRaisesMinV string
+	}{
+		{
+			desc:          "HighlightsReleaseRaisingMinimal",
+			min:           "v2.2.0",
+			cur:           "v2.0.0",
+			expVersions:   []string{"2.4.0", "2.3.0", "2.2.0", "2.1.0"},
+			expRaisesMinV: "2.2.0",
+		},
+		{
+			desc:          "HighlightsOldestReleaseAboveMinimal",
+			min:           "v2.1.5",
+			cur:           "v2.1.0",
+			expVersions:   []string{"2.4.0", "2.3.0", "2.2.0"},
+			expRaisesMinV: "2.2.0",
+		},
+		{
+			desc:        "HighlightsNothingWhenMinimalIsMet",
+			min:         "v2.0.0",
+			cur:         "v2.3.0",
+			expVersions: []string{"2.4.0"},
+		},
+		{
+			desc:        "IsEmptyAtLatest",
+			min:         "v2.0.0",
+			cur:         "v2.4.0",
+			expVersions: []string{},
+		},
+	}
+	for _, tC := range testCases {
+		t.Run(tC.desc, func(t *testing.T) {
+			gc, err := version.NewGithubChecker(context.Background(), newChangelogGitHubMock(), fakeOrg, fakeRepo, tC.min, tC.cur)
+			if err != nil {
+				t.Fatalf("expected nil error, got %s", err)
+			}
+
+			notes, err := gc.Changelog(context.Background())
+			if err != nil {
+				t.Fatalf("expected nil error, got %s", err)
+			}
+
+			versions := []string{}
+			raisesMinV := ""
+			for _, n := range notes {
+				versions = append(versions, n.Version)
+				if n.RaisesMinimal {
+					raisesMinV = n.Version
+				}
+				if n.Body != "Notes for v"+n.Version {
+					t.Errorf("expected notes of %s, got %q", n.Version, n.Body)
+				}
+			}
+			if strings.Join(versions, " ") != strings.Join(tC.expVersions, " ") {
+				t.Errorf("expected changelog of %v, got %v", tC.expVersions, versions)
+			}
+			if raisesMinV != tC.expRaisesMinV {
+				t.Errorf("expected %q to raise the minimal version, got %q", tC.expRaisesMinV, raisesMinV)
+			}
+		})
+	}
+}
+
+func TestGithubCheckerChangelogFailsWithUnknownLatest(t *testing.T) {
+	gc, err := version.NewGithubChecker(context.Background(), newNotReleasedGitHubMock(), fakeOrg, fakeRepo, "v1", "v2")
 	if err != nil {
-		t.Fatalf("error reading downloaded file: %s", err)
+		t.Fatalf("expected nil error, got %s", err)
 	}
 
-	// For some reason the mock returns the fake content wrapped in "",
-	// so we use a contains match. That's good enough for this.
-	if !strings.Contains(string(text), fakeAssetContent) {
-		t.Errorf("== expected file content:\n%s\n== got:\n%s\n", fakeAssetContent, string(text))
+	_, err = gc.Changelog(context.Background())
+	if err == nil {
+		t.Error("expected error for changelog with unknown latest version, got nil")
 	}
+}
 
+func TestGithubCheckerLatestRelease(t *testing.T) {
+	releaseURL := "https://github.com/" + fakeOrg + "/" + fakeRepo + "/releases/tag/v3.0.0"
+	release := fakeGitHubRelease("v3.0.0")
+	release.HTMLURL = strp(releaseURL)
+
+	client := github.NewClient(mock.NewMockedHTTPClient(
+		mock.WithRequestMatch(mock.GetReposReleasesLatestByOwnerByRepo, release),
+	))
+	gc, err := version.NewGithubChecker(context.Background(), client, fakeOrg, fakeRepo, "v1", "v2")
+	if err != nil {
+		t.Fatalf("expected nil error, got %s", err)
+	}
+
+	exp := version.ReleaseInfo{Asset: fakeAssetName("v3.0.0", runtime.GOOS), URL: releaseURL}
+	if r := gc.LatestRelease(); r != exp {
+		t.Errorf("expected latest release %+v, got %+v", exp, r)
+	}
+
+	gc, err = version.NewGithubChecker(context.Background(), newNotReleasedGitHubMock(), fakeOrg, fakeRepo, "v1", "v2")
+	if err != nil {
+		t.Fatalf("expected nil error, got %s", err)
+	}
+	if r := gc.LatestRelease(); r != (version.ReleaseInfo{}) {
+		t.Errorf("expected no latest release with unknown latest version, got %+v", r)
+	}
 }
@@ -0,0 +1,161 @@
+package version
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"net/url"
+	"strings"
+
+	semver "github.com/hashicorp/go-version"
+)
+
+// GitLabChecker is a Checker for releases published as GitLab Releases
+//
+// Release assets are taken from the release links.
+type GitLabChecker struct {
+	forgeChecker
+}
+
+// gitLabRelease is the subset of the GitLab Releases API response we care about
+type gitLabRelease struct {
+	TagName string `json:"tag_name"`
+
+	// UpcomingRelease means the release is dated in the future, not out yet
+	UpcomingRelease bool `json:"upcoming_release"`
+
+	Assets struct {
+		Links []struct {
+			Name           string `json:"name"`
+			URL            string `json:"url"`
+			DirectAssetURL string `json:"direct_asset_url"`
+		} `json:"links"`
+	} `json:"assets"`
+	Links struct {
+		Self string `json:"self"`
+	} `json:"_links"`
+}
+
+// forgeRelease maps r to a forgeRelease, with its links as assets
+func (r *gitLabRelease) forgeRelease() *forgeRelease {
+	fr := forgeRelease{tag: r.TagName, url: r.Links.Self}
+	for _, link := range r.Assets.Links {
+		// prefer direct asset URLs, that survive release link updates
+		u := link.DirectAssetURL
+		if u == "" {
+			u = link.URL
+		}
+		fr.assets = append(fr.assets, forgeAsset{name: link.Name, url: u})
+	}
+	return &fr
+}
+
+// gitLabAPI is the forgeAPI of a GitLab project releases
+type gitLabAPI struct {
+	client     *http.Client
+	projectURL string
+}
+
+// NewGitLabChecker discovers what is the latest version from GitLab Releases
+//
+// baseURL is the GitLab instance address (e.g. https://gitlab.com), and project is
+// either the numeric project ID or its full path (e.g. mygroup/myproject).
+//
+// client should be authenticated for private projects (see gl.NewClient). If nil,
+// http.DefaultClient is used.
+//
+// If policy pins or caps versions, the pinned (or maximal allowed) release is taken as
+// latest instead. Releases policy does not allow (revoked, or outside the version
+// constraint) are never taken as latest: the newest allowed release below them is.
+//
+// It already saves asset information, leaving everything ready for calling Download().
+// Failing to get it in time (before ctx is done) leaves the latest version unknown.
+func NewGitLabChecker(ctx context.Context, client *http.Client, baseURL string, project string, policy Policy, current string) (*GitLabChecker, error) {
+	var err error
+	glc := GitLabChecker{}
+
+	if baseURL == "" || project == "" {
+		return nil, errors.New("error getting latest gitlab release, url or project are unset")
+	}
+
+	if client == nil {
+		client = http.DefaultClient
+	}
+	api := &gitLabAPI{
+		client:     client,
+		projectURL: fmt.Sprintf("%s/api/v4/projects/%s", strings.TrimRight(baseURL, "/"), url.PathEscape(project)),
+	}
+
+	glc.forgeChecker, err = newForgeChecker(ctx, "gitlab", "project "+project, api, client, policy, current)
+	if err != nil {
+		return nil, err
+	}
+
+	return &glc, nil
+}
+
+// latestRelease gets the latest release, which GitLab does not tell: it is the highest
+// version among the most recent releases, ignoring upcoming releases and prereleases
+// (and tags that are not a valid version)
+func (a *gitLabAPI) latestRelease(ctx context.Context) (*forgeRelease, error) {
+	releases, err := a.releases(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	var latest *forgeRelease
+	var latestV *semver.Version
+	for _, r := range releases {
+		v, err := semver.NewSemver(r.tag)
+		if err != nil {
+			log.Printf("ignoring gitlab release with invalid version %q: %s", r.tag, err)
+			continue
+		}
+
+		if v.Prerelease() != "" {
+			continue
+		}
+
+		if latestV == nil || v.GreaterThan(latestV) {
+			latest, latestV = r, v
+		}
+	}
+
+	if latest == nil {
+		return nil, errNoReleases
+	}
+
+	return latest, nil
+}
+
+// releaseByTag gets the release tagged tag
+func (a *gitLabAPI) releaseByTag(ctx context.Context, tag string) (*forgeRelease, error) {
+	release := gitLabRelease{}
+	err := getJSON(ctx, a.client, a.projectURL+"/releases/"+url.PathEscape(tag), &release)
+	if err != nil {
+		return nil, err
+	}
+
+	return release.forgeRelease(), nil
+}
+
+// releases lists the most recent releases, ignoring upcoming releases (GitLab has no
+// drafts, but these are not out yet either)
+func (a *gitLabAPI) releases(ctx context.Context) ([]*forgeRelease, error) {
+	var releases []gitLabRelease
+	err := getJSON(ctx, a.client, fmt.Sprintf("%s/releases?per_page=%d", a.projectURL, maxChannelReleases), &releases)
+	if err != nil {
+		return nil, err
+	}
+
+	frs := []*forgeRelease{}
+	for i := range releases {
+		if !releases[i].UpcomingRelease {
+			frs = append(frs, releases[i].forgeRelease())
+		}
+	}
+
+	return frs, nil
+}
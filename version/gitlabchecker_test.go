@@ -0,0 +1,238 @@
+package version_test
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/dgmorales/go-cli-selfupdate/gl"
+	"github.com/dgmorales/go-cli-selfupdate/version"
+)
+
+const fakeGitLabToken = "glpat-not-really-a-token"
+
+// gitLabMockRelease is a release served by newGitLabReleasesMock
+type gitLabMockRelease struct {
+	tag      string
+	upcoming bool
+}
+
+// newGitLabMock starts a local stand-in for the GitLab Releases API, serving fakeOrg/fakeRepo
+// with latest release set to latestV (or no releases at all, if latestV is blank)
+//
+// If private is true, it answers as GitLab does for private projects (404) unless the
+// request carries the fake token.
+func newGitLabMock(t *testing.T, latestV string, private bool) *httptest.Server {
+	t.Helper()
+
+	releases := []gitLabMockRelease{}
+	if strings.TrimSpace(latestV) != "" {
+		releases = append(releases, gitLabMockRelease{tag: latestV})
+	}
+
+	return newGitLabReleasesMock(t, releases, private)
+}
+
+// newGitLabReleasesMock is like newGitLabMock, but serving the given releases, listed in
+// that order (GitLab lists the most recently released first)
+func newGitLabReleasesMock(t *testing.T, mockReleases []gitLabMockRelease, private bool) *httptest.Server {
+	t.Helper()
+
+	var srv *httptest.Server
+	srv = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if private && r.Header.Get("PRIVATE-TOKEN") != fakeGitLabToken {
+			http.Error(w, `{"message":"404 Project Not Found"}`, http.StatusNotFound)
+			return
+		}
+
+		switch r.URL.EscapedPath() {
+		case fmt.Sprintf("/api/v4/projects/%s%%2F%s/releases", fakeOrg, fakeRepo):
+			releases := []map[string]interface{}{}
+			for _, mr := range mockReleases {
+				links := []map[string]interface{}{}
+				for _, goos := range []string{"darwin", "linux", "windows"} {
+					name := fakeAssetName(mr.tag, goos)
+					links = append(links, map[string]interface{}{
+						"name":             name,
+						"url":              srv.URL + "/uploads/" + name,
+						"direct_asset_url": srv.URL + "/downloads/" + name,
+					})
+				}
+				releases = append(releases, map[string]interface{}{
+					"tag_name":         mr.tag,
+					"upcoming_release": mr.upcoming,
+					"assets":           map[string]interface{}{"links": links},
+				})
+			}
+			w.Header().Set("Content-Type", "application/json")
+			json.NewEncoder(w).Encode(releases)
+
+		default:
+			if strings.HasPrefix(r.URL.Path, "/downloads/") {
+				fmt.Fprint(w, fakeAssetContent)
+				return
+			}
+			http.NotFound(w, r)
+		}
+	}))
+	t.Cleanup(srv.Close)
+
+	return srv
+}
+
+func TestGitLabCheckerImplementsChecker(t *testing.T) {
+	var i interface{} = new(version.GitLabChecker)
+	if _, ok := i.(version.Checker); !ok {
+		t.Fatalf("expected %T to implement version.Checker", i)
+	}
+}
+
+func TestNewGitLabCheckerWorksWithValidVersions(t *testing.T) {
+	testCases := []versionsCaseSpec{
+		{
+			desc:   "With non-prefixed and v-prefixed versions",
+			min:    "1.1.9",
+			cur:    "v2.0.0",
+			latest: "v2.3.4",
+		},
+		{
+			desc:   "With unset minimal required version",
+			min:    "",
+			cur:    "v2.2.1",
+			latest: "v2.5.1",
+		},
+		{
+			desc:   "With no releases at all",
+			min:    "1.0.0",
+			cur:    "v2.2.1",
+			latest: "",
+		},
+	}
+	for _, tC := range testCases {
+		t.Run(tC.desc, func(t *testing.T) {
+			srv := newGitLabMock(t, tC.latest, false)
+
+			gc, err := version.NewGitLabChecker(context.Background(), srv.Client(), srv.URL, fakeOrg+"/"+fakeRepo, version.Policy{MinimalRequired: tC.min}, tC.cur)
+			if err != nil {
+				t.Fatalf("expected nil error, got %s", err.Error())
+			}
+
+			verifyExpectedVersions(t, gc, tC)
+		})
+	}
+}
+
+func TestNewGitLabCheckerFailsWithInvalidVersions(t *testing.T) {
+	testCases := []versionsCaseSpec{
+		{
+			desc:   "Invalid minimal required version",
+			min:    "banana",
+			cur:    "2.2.1",
+			latest: "2.5.1",
+		},
+		{
+			desc:   "Invalid current version",
+			min:    "v1.0.0",
+			cur:    "gold",
+			latest: "v2.5.1",
+		},
+	}
+	for _, tC := range testCases {
+		t.Run(tC.desc, func(t *testing.T) {
+			srv := newGitLabMock(t, tC.latest, false)
+
+			_, err := version.NewGitLabChecker(context.Background(), srv.Client(), srv.URL, fakeOrg+"/"+fakeRepo, version.Policy{MinimalRequired: tC.min}, tC.cur)
+			if err == nil {
+				t.Errorf("expected error, got nil")
+			}
+		})
+	}
+}
+
+func TestNewGitLabCheckerPicksHighestReleasedVersion(t *testing.T) {
+	testCases := []struct {
+		desc     string
+		releases []gitLabMockRelease
+		latest   string
+	}{
+		{
+			desc:     "IgnoresUpcomingRelease",
+			releases: []gitLabMockRelease{{tag: "v3.0.0", upcoming: true}, {tag: "v2.5.0"}},
+			latest:   "v2.5.0",
+		},
+		{
+			desc:     "IgnoresPrerelease",
+			releases: []gitLabMockRelease{{tag: "v3.0.0-rc.1"}, {tag: "v2.5.0"}},
+			latest:   "v2.5.0",
+		},
+		{
+			desc:     "IgnoresInvalidVersion",
+			releases: []gitLabMockRelease{{tag: "silver"}, {tag: "v2.5.0"}},
+			latest:   "v2.5.0",
+		},
+		{
+			desc:     "PicksHighestVersionNotMostRecentRelease",
+			releases: []gitLabMockRelease{{tag: "v2.4.1"}, {tag: "v2.5.0"}},
+			latest:   "v2.5.0",
+		},
+		{
+			desc:     "LatestIsUnknownWithOnlyPrereleases",
+			releases: []gitLabMockRelease{{tag: "v3.0.0-rc.1"}},
+		},
+	}
+	for _, tC := range testCases {
+		t.Run(tC.desc, func(t *testing.T) {
+			srv := newGitLabReleasesMock(t, tC.releases, false)
+
+			gc, err := version.NewGitLabChecker(context.Background(), srv.Client(), srv.URL, fakeOrg+"/"+fakeRepo, version.Policy{MinimalRequired: "1.0.0"}, "v2.0.0")
+			if err != nil {
+				t.Fatalf("expected nil error, got %s", err)
+			}
+
+			verifyExpectedVersions(t, gc, versionsCaseSpec{min: "1.0.0", cur: "v2.0.0", latest: tC.latest})
+		})
+	}
+}
+
+func TestGitLabCheckerLatestIsUnknownForPrivateProjectWithoutToken(t *testing.T) {
+	srv := newGitLabMock(t, "v3", true)
+
+	gc, err := version.NewGitLabChecker(context.Background(), srv.Client(), srv.URL, fakeOrg+"/"+fakeRepo, version.Policy{MinimalRequired: "v1"}, "v2")
+	if err != nil {
+		t.Fatalf("expected nil error, got %s", err)
+	}
+
+	if ans := gc.Check(); ans != version.IsUnknown {
+		t.Errorf("expected '%d/%s', got '%d/%s'", version.IsUnknown, assertStr(version.IsUnknown), ans, assertStr(ans))
+	}
+}
+
+func TestGitLabCheckerDownloadLatestFromPrivateProject(t *testing.T) {
+	srv := newGitLabMock(t, "v3", true)
+
+	t.Setenv("GITLAB_TOKEN", fakeGitLabToken)
+	client, err := gl.NewClient(srv.URL)
+	if err != nil {
+		t.Fatalf("expected nil error creating client, got %s", err)
+	}
+
+	gc, err := version.NewGitLabChecker(context.Background(), client, srv.URL, fakeOrg+"/"+fakeRepo, version.Policy{MinimalRequired: "v1"}, "v2")
+	if err != nil {
+		t.Fatalf("expected nil error, got %s", err)
+	}
+
+	if ans := gc.Check(); ans != version.CanUpdate {
+		t.Fatalf("expected '%d/%s', got '%d/%s'", version.CanUpdate, assertStr(version.CanUpdate), ans, assertStr(ans))
+	}
+
+	asset, err := gc.DownloadLatest(context.Background())
+	if err != nil {
+		t.Fatalf("expected nil error on download, got %s", err)
+	}
+
+	verifyDownloadedFile(t, asset)
+}
@@ -0,0 +1,402 @@
+package version
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net/url"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+
+	semver "github.com/hashicorp/go-version"
+)
+
+// indexNames are the release index file names we look for at the root of a release
+// directory, in order. They are release manifests (see releaseManifest).
+var indexNames = []string{"index.yaml", "index.yml", "index.json"}
+
+// LocalDirChecker is a Checker for releases stored in a local directory (like a shared
+// mount), for environments without access to the internet.
+//
+// The directory is laid out as <version>/<asset>, for example:
+//
+//	releases/
+//	  index.yaml
+//	  v0.3.3/go-cli-selfupdate-v0.3.3-linux-amd64.tar.gz
+//	  v0.4.0/go-cli-selfupdate-v0.4.0-linux-amd64.tar.gz
+//
+// If there is an index (a release manifest named index.yaml, index.yml or index.json),
+// it is used to find releases and their assets, with relative asset URLs taken as
+// relative to the directory. Otherwise, the version directories are listed and the
+// asset picked by its name.
+type LocalDirChecker struct {
+	versionSet
+	downloader
+	dir       string
+	assetPath string
+	asset     *manifestAsset
+}
+
+// NewLocalDirChecker discovers what is the latest version from the release directory
+// dir, which may be a path or a file:// URL.
+//
+// If policy pins or caps versions, the pinned (or maximal allowed) release is taken as
+// latest instead. Releases policy does not allow (revoked, or outside the version
+// constraint) are never taken as latest: the newest allowed release below them is.
+//
+// It already saves asset information, leaving everything ready for calling Download()
+func NewLocalDirChecker(dir string, policy Policy, current string) (*LocalDirChecker, error) {
+	var err error
+	ldc := LocalDirChecker{}
+
+	ldc.dir, err = localPath(dir)
+	if err != nil {
+		return nil, err
+	}
+	if ldc.dir == "" {
+		return nil, errors.New("error getting latest release, release directory is unset")
+	}
+
+	ldc.versionSet, err = newVersionSet(policy, current)
+	if err != nil {
+		return nil, err
+	}
+
+	m, err := ldc.releases()
+	if err != nil {
+		// We won't error if we can't get the latest version.
+		// Instead we will proceed without the latest version (and assets) info
+		ldc.latestErr = fmt.Errorf("error reading releases from directory %s: %s", ldc.dir, err)
+		log.Printf("%s. Will ignore and continue with latest version as unknown", ldc.latestErr)
+		return &ldc, nil
+	}
+
+	latest, latestV, err := m.target(&ldc.versionSet)
+	if err != nil {
+		// Same as above: no usable (or allowed) release leaves the latest version unknown
+		ldc.latestErr = fmt.Errorf("error getting latest release from directory %s: %w", ldc.dir, err)
+		log.Printf("%s. Will ignore and continue with latest version as unknown", ldc.latestErr)
+		return &ldc, nil
+	}
+	ldc.latest = latestV
+	ldc.release.URL = ldc.dir
+
+	asset, err := latest.platformAsset()
+	if err != nil {
+		log.Printf("error picking release asset from directory %s: %s", ldc.dir, err)
+		return &ldc, nil
+	}
+	ldc.asset = asset
+	ldc.assetPath, err = ldc.localAssetPath(asset)
+	if err != nil {
+		return nil, err
+	}
+	ldc.release.Asset = filepath.Base(ldc.assetPath)
+
+	return &ldc, nil
+}
+
+// localAssetPath returns the path to asset, taking relative asset URLs as relative to
+// the release directory
+func (c *LocalDirChecker) localAssetPath(asset *manifestAsset) (string, error) {
+	return c.releasePath(asset.URL)
+}
+
+// releasePath returns the path to the file at ref (a path or file:// URL), taking
+// relative ones as relative to the release directory
+func (c *LocalDirChecker) releasePath(ref string) (string, error) {
+	p, err := localPath(ref)
+	if err != nil {
+		return "", err
+	}
+	if !filepath.IsAbs(p) {
+		p = filepath.Join(c.dir, p)
+	}
+
+	return p, nil
+}
+
+// localPath converts a file:// URL to a local path. Anything without a scheme is taken as
+// a path already.
+func localPath(s string) (string, error) {
+	if !strings.Contains(s, "://") {
+		return filepath.FromSlash(s), nil
+	}
+
+	u, err := url.Parse(s)
+	if err != nil {
+		return "", err
+	}
+	if u.Scheme != "file" {
+		return "", fmt.Errorf("unsupported url %s for local release directory, only file:// is supported", s)
+	}
+
+	return filepath.FromSlash(u.Path), nil
+}
+
+// releases returns the releases in the release directory, from its index or, if there
+// is none, from its layout
+func (c *LocalDirChecker) releases() (*releaseManifest, error) {
+	m, err := c.readIndex()
+	if errors.Is(err, os.ErrNotExist) {
+		return c.scan()
+	}
+
+	return m, err
+}
+
+// readIndex reads the release index at the root of the release directory.
+//
+// It returns an error matching os.ErrNotExist if there is none.
+func (c *LocalDirChecker) readIndex() (*releaseManifest, error) {
+	for _, name := range indexNames {
+		data, err := os.ReadFile(filepath.Join(c.dir, name))
+		if errors.Is(err, os.ErrNotExist) {
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+
+		return parseManifest(data)
+	}
+
+	return nil, fmt.Errorf("no release index found in %s: %w", c.dir, os.ErrNotExist)
+}
+
+// scan builds a release manifest from the <version>/<asset> layout of the release
+// directory, for when there is no index.
+func (c *LocalDirChecker) scan() (*releaseManifest, error) {
+	entries, err := os.ReadDir(c.dir)
+	if err != nil {
+		return nil, err
+	}
+
+	m := releaseManifest{SchemaVersion: manifestSchemaVersion}
+	for _, e := range entries {
+		if !e.IsDir() {
+			continue
+		}
+
+		// ignore anything not named as a version
+		v, err := semver.NewSemver(e.Name())
+		if err != nil {
+			continue
+		}
+
+		files, err := os.ReadDir(filepath.Join(c.dir, e.Name()))
+		if err != nil {
+			return nil, err
+		}
+
+		names := []string{}
+		for _, f := range files {
+			if !f.IsDir() {
+				names = append(names, f.Name())
+			}
+		}
+
+		r := manifestRelease{Version: e.Name()}
+		asset, err := c.scanAsset(e.Name(), v, names)
+		if err != nil {
+			log.Printf("ignoring assets of release %s in %s: %s", e.Name(), c.dir, err)
+		} else {
+			r.Assets = append(r.Assets, *asset)
+		}
+		m.Releases = append(m.Releases, r)
+	}
+
+	return &m, nil
+}
+
+// scanAsset returns the asset of release v, in the release directory subdir, for the
+// platform we are running on, given the names of the files there.
+//
+// If there is a checksum manifest among them, it must list the asset. The binary
+// signature is picked too, if there is one, and so are patches, if the checksum manifest
+// lists the binary to verify them.
+func (c *LocalDirChecker) scanAsset(subdir string, v *semver.Version, names []string) (*manifestAsset, error) {
+	i, err := c.platformAsset(names, v)
+	if err != nil {
+		return nil, err
+	}
+
+	// platformAsset already matched the asset for our platform
+	asset := manifestAsset{
+		OS:   runtime.GOOS,
+		Arch: runtime.GOARCH,
+		URL:  filepath.ToSlash(filepath.Join(subdir, names[i])),
+	}
+
+	if j := checksumManifest(names); j >= 0 {
+		data, err := os.ReadFile(filepath.Join(c.dir, subdir, names[j]))
+		if err != nil {
+			return nil, err
+		}
+
+		sums, err := parseChecksums(data)
+		if err != nil {
+			return nil, err
+		}
+
+		asset.SHA256, asset.BinarySHA256 = sums[names[i]], sums[binaryName(names[i])]
+		if asset.SHA256 == "" {
+			return nil, fmt.Errorf("no checksum for %s in %s", names[i], names[j])
+		}
+
+		for _, name := range names {
+			if from, ok := patchFrom(name, names[i]); ok {
+				asset.Patches = append(asset.Patches, manifestPatch{
+					From:   from.Original(),
+					URL:    filepath.ToSlash(filepath.Join(subdir, name)),
+					SHA256: sums[name],
+				})
+			}
+		}
+	}
+
+	if j := signatureAsset(names, names[i]); j >= 0 {
+		asset.Signature = filepath.ToSlash(filepath.Join(subdir, names[j]))
+	}
+
+	return &asset, nil
+}
+
+// DownloadLatest copies the saved release asset to a temporary file, verifying its size
+// and checksum if the index has them
+func (c *LocalDirChecker) DownloadLatest(ctx context.Context) (*Asset, error) {
+	if c == nil {
+		return nil, fmt.Errorf("in LocalDirChecker.DownloadLatest: called with nil receiver")
+	}
+
+	if c.asset == nil || c.assetPath == "" {
+		return nil, errors.New("in Download: release asset information is unavailable")
+	}
+
+	return c.copyAsset(ctx, c.asset, c.assetPath)
+}
+
+// DownloadVersion copies the release asset of version v to a temporary file, verifying
+// its size and checksum if the index has them
+func (c *LocalDirChecker) DownloadVersion(ctx context.Context, v string) (*Asset, error) {
+	if c == nil {
+		return nil, fmt.Errorf("in LocalDirChecker.DownloadVersion: called with nil receiver")
+	}
+
+	asset, err := c.versionAsset(v)
+	if err != nil {
+		return nil, err
+	}
+
+	assetPath, err := c.localAssetPath(asset)
+	if err != nil {
+		return nil, fmt.Errorf("in Download: %w", err)
+	}
+
+	return c.copyAsset(ctx, asset, assetPath)
+}
+
+// DownloadPatch copies the patch from the current version to version v to a temporary
+// file, verifying its size and checksum if the index has them
+func (c *LocalDirChecker) DownloadPatch(ctx context.Context, v string) (*Asset, error) {
+	if c == nil {
+		return nil, fmt.Errorf("in LocalDirChecker.DownloadPatch: called with nil receiver")
+	}
+
+	asset := c.asset
+	if !c.isLatest(v) || asset == nil {
+		var err error
+		asset, err = c.versionAsset(v)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	patch := asset.patchFrom(c.current)
+	if patch == nil {
+		return nil, ErrNoPatch
+	}
+
+	patchPath, err := c.localAssetPath(patch)
+	if err != nil {
+		return nil, fmt.Errorf("in Download: %w", err)
+	}
+
+	return patched(c.copyAsset(ctx, patch, patchPath))
+}
+
+// versionAsset returns the release asset of version v, from a fresh read of the release
+// directory
+func (c *LocalDirChecker) versionAsset(v string) (*manifestAsset, error) {
+	want, err := semver.NewSemver(v)
+	if err != nil {
+		return nil, fmt.Errorf("in Download: %w", err)
+	}
+
+	m, err := c.releases()
+	if err != nil {
+		return nil, fmt.Errorf("in Download: error reading releases from directory %s: %w", c.dir, err)
+	}
+
+	release, _, err := m.release(want)
+	if err != nil {
+		return nil, fmt.Errorf("in Download: %w", err)
+	}
+
+	asset, err := release.platformAsset()
+	if err != nil {
+		return nil, fmt.Errorf("in Download: %w", err)
+	}
+
+	return asset, nil
+}
+
+// copyAsset copies asset from assetPath to a temporary file, verifying it. Its signature,
+// if any, is not copied: it is only read.
+//
+// The copy stops when ctx is done (a shared mount may be slow). Unlike downloads, an
+// interrupted copy is not resumed: it is removed, and the next one starts from scratch.
+func (c *LocalDirChecker) copyAsset(ctx context.Context, asset *manifestAsset, assetPath string) (*Asset, error) {
+	src, err := os.Open(assetPath)
+	if err != nil {
+		return nil, fmt.Errorf("in Download: %w", err)
+	}
+	defer src.Close()
+
+	info, err := src.Stat()
+	if err != nil {
+		return nil, fmt.Errorf("in Download: %w", err)
+	}
+
+	filename, f, err := openFileForDownload(filepath.Base(assetPath))
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	w := progressWriter{w: f, name: filepath.Base(assetPath), total: info.Size(), progress: c.progress}
+	_, err = io.Copy(&w, contextReader{ctx, src})
+	if err != nil {
+		f.Close()
+		os.Remove(filename)
+		return nil, fmt.Errorf("in Download: %w", err)
+	}
+
+	a, err := checkedAsset(filename, asset.Size, asset.SHA256, asset.BinarySHA256)
+	if err != nil {
+		return nil, err
+	}
+
+	if asset.Signature != "" {
+		a.Signature, err = c.releasePath(asset.Signature)
+		if err != nil {
+			return nil, fmt.Errorf("in Download: %w", err)
+		}
+	}
+
+	return a, nil
+}
@@ -0,0 +1,203 @@
+package version_test
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"testing"
+
+	"github.com/dgmorales/go-cli-selfupdate/version"
+)
+
+// newReleaseDir creates a release directory laid out as <version>/<asset>, with assets
+// for every OS, on each of the given versions.
+//
+// If index is not blank, it is written as index.yaml.
+func newReleaseDir(t *testing.T, index string, versions ...string) string {
+	t.Helper()
+
+	dir := t.TempDir()
+	for _, v := range versions {
+		err := os.Mkdir(filepath.Join(dir, v), 0755)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		for _, goos := range []string{"darwin", "linux", "windows"} {
+			err = os.WriteFile(filepath.Join(dir, v, fakeAssetName(v, goos)), []byte(fakeAssetContent), 0644)
+			if err != nil {
+				t.Fatal(err)
+			}
+		}
+	}
+
+	if index != "" {
+		err := os.WriteFile(filepath.Join(dir, "index.yaml"), []byte(index), 0644)
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	return dir
+}
+
+func TestLocalDirCheckerImplementsChecker(t *testing.T) {
+	var i interface{} = new(version.LocalDirChecker)
+	if _, ok := i.(version.Checker); !ok {
+		t.Fatalf("expected %T to implement version.Checker", i)
+	}
+}
+
+func TestNewLocalDirChecker(t *testing.T) {
+	testCases := []struct {
+		desc     string
+		index    string
+		versions []string
+		spec     versionsCaseSpec
+	}{
+		{
+			desc:     "FindsLatestVersionDirectoryWithoutIndex",
+			versions: []string{"v1.0.0", "v2.10.0", "v2.9.0", "v3.0.0-rc.1"},
+			spec:     versionsCaseSpec{min: "1.0.0", cur: "v2.0.0", latest: "v2.10.0"},
+		},
+		{
+			desc:     "UsesIndexWhenPresent",
+			index:    yamlManifest(fakeAssetSHA256(), "v2.9.0"),
+			versions: []string{"v2.9.0", "v2.10.0"},
+			spec:     versionsCaseSpec{min: "1.0.0", cur: "v2.0.0", latest: "v2.9.0"},
+		},
+		{
+			desc:     "SkipsInvalidVersionInIndex",
+			index:    yamlManifest(fakeAssetSHA256(), "v2.9.0", "silver"),
+			versions: []string{"v2.9.0"},
+			spec:     versionsCaseSpec{min: "1.0.0", cur: "v2.0.0", latest: "v2.9.0"},
+		},
+		{
+			desc:  "LatestIsUnknownWithOnlyInvalidVersionsInIndex",
+			index: yamlManifest(fakeAssetSHA256(), "silver"),
+			spec:  versionsCaseSpec{min: "1.0.0", cur: "v2.0.0", latest: ""},
+		},
+		{
+			desc: "LatestIsUnknownWithEmptyDirectory",
+			spec: versionsCaseSpec{min: "1.0.0", cur: "v2.0.0", latest: ""},
+		},
+	}
+	for _, tC := range testCases {
+		t.Run(tC.desc, func(t *testing.T) {
+			dir := newReleaseDir(t, tC.index, tC.versions...)
+
+			lc, err := version.NewLocalDirChecker(dir, version.Policy{MinimalRequired: tC.spec.min}, tC.spec.cur)
+			if err != nil {
+				t.Fatalf("expected nil error, got %s", err)
+			}
+
+			verifyExpectedVersions(t, lc, tC.spec)
+			if tC.spec.latest == "" && lc.LatestErr() == nil {
+				t.Error("expected error on why latest version is unknown, got nil")
+			}
+		})
+	}
+}
+
+func TestNewLocalDirCheckerLatestIsUnknownWhenDirectoryIsMissing(t *testing.T) {
+	lc, err := version.NewLocalDirChecker(filepath.Join(t.TempDir(), "missing"), version.Policy{MinimalRequired: "v1"}, "v2")
+	if err != nil {
+		t.Fatalf("expected nil error, got %s", err)
+	}
+
+	if ans := lc.Check(); ans != version.IsUnknown {
+		t.Errorf("expected '%d/%s', got '%d/%s'", version.IsUnknown, assertStr(version.IsUnknown), ans, assertStr(ans))
+	}
+}
+
+func TestNewLocalDirCheckerFailsWithNonFileURL(t *testing.T) {
+	_, err := version.NewLocalDirChecker("https://example.com/releases", version.Policy{MinimalRequired: "v1"}, "v2")
+	if err == nil {
+		t.Errorf("expected error, got nil")
+	}
+}
+
+func TestLocalDirCheckerDownloadLatest(t *testing.T) {
+	testCases := []struct {
+		desc       string
+		index      string
+		fileURL    bool
+		shouldFail bool
+	}{
+		{
+			desc: "WorksWithoutIndex",
+		},
+		{
+			desc:    "WorksWithFileURL",
+			fileURL: true,
+		},
+		{
+			desc:  "VerifiesChecksumFromIndex",
+			index: yamlManifest(fakeAssetSHA256(), "v3.0.0"),
+		},
+		{
+			desc:       "FailsWithWrongChecksumFromIndex",
+			index:      yamlManifest("badc0ffee", "v3.0.0"),
+			shouldFail: true,
+		},
+	}
+	for _, tC := range testCases {
+		t.Run(tC.desc, func(t *testing.T) {
+			dir := newReleaseDir(t, tC.index, "v3.0.0")
+			if tC.index != "" {
+				// the asset path yamlManifest lists for our platform
+				err := os.WriteFile(filepath.Join(dir, "v3.0.0", "test-v3.0.0.tar.gz"), []byte(fakeAssetContent), 0644)
+				if err != nil {
+					t.Fatal(err)
+				}
+			}
+			if tC.fileURL {
+				dir = "file://" + filepath.ToSlash(dir)
+			}
+
+			lc, err := version.NewLocalDirChecker(dir, version.Policy{MinimalRequired: "v1"}, "v2")
+			if err != nil {
+				t.Fatalf("expected nil error, got %s", err)
+			}
+
+			asset, err := lc.DownloadLatest(context.Background())
+			if tC.shouldFail {
+				if err == nil {
+					t.Fatal("expected error on download, got nil")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("expected nil error on download, got %s", err)
+			}
+
+			verifyDownloadedFile(t, asset)
+		})
+	}
+}
+
+func TestLocalDirCheckerPicksSignature(t *testing.T) {
+	dir := newReleaseDir(t, "", "v3.0.0")
+	archive := fakeAssetName("v3.0.0", runtime.GOOS)
+	signature := filepath.Join(dir, "v3.0.0", strings.TrimSuffix(strings.TrimSuffix(archive, ".tar.gz"), ".zip")+".minisig")
+	err := os.WriteFile(signature, []byte("fake signature"), 0644)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	lc, err := version.NewLocalDirChecker(dir, version.Policy{}, "v2")
+	if err != nil {
+		t.Fatalf("expected nil error, got %s", err)
+	}
+
+	asset, err := lc.DownloadLatest(context.Background())
+	if err != nil {
+		t.Fatalf("expected nil error on download, got %s", err)
+	}
+
+	if asset.Signature != signature {
+		t.Errorf("expected signature %s, got %q", signature, asset.Signature)
+	}
+}
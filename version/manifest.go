@@ -0,0 +1,253 @@
+package version
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"runtime"
+	"strings"
+	"time"
+
+	semver "github.com/hashicorp/go-version"
+	"sigs.k8s.io/yaml"
+)
+
+// manifestSchemaVersion is the release manifest format version we understand
+const manifestSchemaVersion = 1
+
+// errNoReleases means a release source has no (non-prerelease) releases at all
+var errNoReleases = errors.New("no releases found")
+
+// releaseManifest lists published releases and their assets, for release sources that
+// are not a forge with a releases API (plain web servers, directories).
+//
+// It may be written in JSON or YAML. Example:
+//
+//	schemaVersion: 1
+//	releases:
+//	  - version: v0.4.0
+//	    date: 2022-12-01T10:00:00Z
+//	    assets:
+//	      - os: linux
+//	        arch: amd64
+//	        url: v0.4.0/go-cli-selfupdate-v0.4.0-linux-amd64.tar.gz
+//	        size: 23456789
+//	        sha256: 9f86d081884c7d659a2feaa0c55ad015a3bf4f1b2b0b822cd15d6c15b0f00a08
+//	        binarySha256: 60303ae22b998861bce3b28f33eec1be758a213c86c93c076dbe9f558c11c752
+//	        signature: v0.4.0/go-cli-selfupdate-v0.4.0-linux-amd64.minisig
+//	        patches:
+//	          - from: v0.3.0
+//	            url: v0.4.0/go-cli-selfupdate-v0.4.0-linux-amd64.from-v0.3.0.bsdiff
+//	            size: 345678
+//	            sha256: 2c26b46b68ffc68ff99b453c1d30413413422d706483bfa0f98a5e886266e7ae
+//
+// Asset URLs may be relative to the manifest location. The sha256 checksum is of the
+// asset (an archive), and the optional binarySha256 checksum of the binary in it. So is
+// the optional signature URL: of a minisign signature of the binary.
+//
+// Patches are optional bsdiff patches from the binary of a previous version to the
+// binary in the asset. They are only used if the asset has binarySha256, to verify the
+// patched binary.
+type releaseManifest struct {
+	SchemaVersion int               `json:"schemaVersion"`
+	Releases      []manifestRelease `json:"releases"`
+}
+
+type manifestRelease struct {
+	Version string          `json:"version"`
+	Date    time.Time       `json:"date,omitempty"`
+	Assets  []manifestAsset `json:"assets"`
+}
+
+type manifestAsset struct {
+	OS     string `json:"os"`
+	Arch   string `json:"arch,omitempty"`
+	URL    string `json:"url"`
+	Size   int64  `json:"size,omitempty"`
+	SHA256 string `json:"sha256,omitempty"`
+
+	BinarySHA256 string `json:"binarySha256,omitempty"`
+	Signature    string `json:"signature,omitempty"`
+
+	Patches []manifestPatch `json:"patches,omitempty"`
+}
+
+// manifestPatch is a bsdiff patch from the binary of version From to the binary of a
+// release asset
+type manifestPatch struct {
+	From   string `json:"from"`
+	URL    string `json:"url"`
+	Size   int64  `json:"size,omitempty"`
+	SHA256 string `json:"sha256,omitempty"`
+}
+
+// patchFrom returns the patch from version current to the binary of the asset, as an
+// asset on its own, or nil if there is none (or the asset has no binary checksum to
+// verify it)
+func (a *manifestAsset) patchFrom(current *semver.Version) *manifestAsset {
+	if a.BinarySHA256 == "" || current == nil {
+		return nil
+	}
+
+	for _, p := range a.Patches {
+		from, err := semver.NewSemver(p.From)
+		if err == nil && from.Equal(current) {
+			return &manifestAsset{
+				OS:           a.OS,
+				Arch:         a.Arch,
+				URL:          p.URL,
+				Size:         p.Size,
+				SHA256:       p.SHA256,
+				BinarySHA256: a.BinarySHA256,
+				Signature:    a.Signature,
+			}
+		}
+	}
+
+	return nil
+}
+
+// parseManifest parses a JSON or YAML release manifest
+func parseManifest(data []byte) (*releaseManifest, error) {
+	m := releaseManifest{}
+
+	err := yaml.Unmarshal(data, &m)
+	if err != nil {
+		return nil, fmt.Errorf("error parsing release manifest: %w", err)
+	}
+
+	if m.SchemaVersion != manifestSchemaVersion {
+		return nil, fmt.Errorf("unsupported release manifest schema version %d (expected %d)",
+			m.SchemaVersion, manifestSchemaVersion)
+	}
+
+	return &m, nil
+}
+
+// versions returns the versions of the releases, in the same order, leaving nil the ones
+// that are prereleases or invalid (which are logged and ignored, not to let a single bad
+// entry hide every other release)
+func (m *releaseManifest) versions() []*semver.Version {
+	versions := make([]*semver.Version, len(m.Releases))
+	for i, r := range m.Releases {
+		v, err := semver.NewSemver(r.Version)
+		if err != nil {
+			log.Printf("ignoring release with invalid version %q in release manifest: %s", r.Version, err)
+			continue
+		}
+
+		if v.Prerelease() == "" {
+			versions[i] = v
+		}
+	}
+
+	return versions
+}
+
+// latest returns the release with the highest version, ignoring prereleases (just like
+// GitHub does for its latest release) and releases with invalid versions
+func (m *releaseManifest) latest(versions []*semver.Version) (*manifestRelease, *semver.Version, error) {
+	var latest *manifestRelease
+	var latestV *semver.Version
+
+	for i, v := range versions {
+		if v == nil {
+			continue
+		}
+
+		if latestV == nil || v.GreaterThan(latestV) {
+			latest, latestV = &m.Releases[i], v
+		}
+	}
+
+	if latest == nil {
+		return nil, nil, errNoReleases
+	}
+
+	return latest, latestV, nil
+}
+
+// target returns the release users should be running, given the version policy in vs:
+// the latest one, unless policy pins or caps versions. If policy does not allow it (like
+// when it is revoked), the newest older (non-prerelease) release it allows is taken
+// instead.
+func (m *releaseManifest) target(vs *versionSet) (*manifestRelease, *semver.Version, error) {
+	if vs.pinned != nil {
+		return m.release(vs.pinned)
+	}
+
+	versions := m.versions()
+	latest, latestV, err := m.latest(versions)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	if want := vs.limit(latestV); want != nil {
+		latest, latestV, err = m.release(want)
+		if err != nil {
+			return nil, nil, err
+		}
+	}
+
+	if vs.allowed(latestV) {
+		return latest, latestV, nil
+	}
+
+	i := vs.fallback(latestV, versions)
+	if i < 0 {
+		return nil, nil, fmt.Errorf("%w (%s is not)", errNoAllowedRelease, latestV)
+	}
+
+	return &m.Releases[i], versions[i], nil
+}
+
+// release returns the release of version want
+func (m *releaseManifest) release(want *semver.Version) (*manifestRelease, *semver.Version, error) {
+	for i, r := range m.Releases {
+		v, err := semver.NewSemver(r.Version)
+		if err == nil && v.Equal(want) {
+			return &m.Releases[i], v, nil
+		}
+	}
+
+	return nil, nil, fmt.Errorf("%w for version %s", errNoReleases, want)
+}
+
+// platformAsset returns the release asset for the platform we are running on.
+//
+// An asset with no arch set matches any arch, but one with our arch set is preferred. It
+// fails if no asset, or more than one equally fitting, matches.
+func (r *manifestRelease) platformAsset() (*manifestAsset, error) {
+	exact, anyArch := []int{}, []int{}
+	for i, a := range r.Assets {
+		if a.OS != runtime.GOOS {
+			continue
+		}
+
+		switch a.Arch {
+		case runtime.GOARCH:
+			exact = append(exact, i)
+		case "":
+			anyArch = append(anyArch, i)
+		}
+	}
+
+	found := exact
+	if len(found) == 0 {
+		found = anyArch
+	}
+
+	switch len(found) {
+	case 0:
+		return nil, fmt.Errorf("release %s has no asset for %s/%s", r.Version, runtime.GOOS, runtime.GOARCH)
+	case 1:
+		return &r.Assets[found[0]], nil
+	}
+
+	matched := make([]string, len(found))
+	for i, f := range found {
+		matched[i] = r.Assets[f].URL
+	}
+	return nil, fmt.Errorf("ambiguous assets for %s/%s in release %s, all of %s match",
+		runtime.GOOS, runtime.GOARCH, r.Version, strings.Join(matched, ", "))
+}
@@ -0,0 +1,257 @@
+package version
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"net/url"
+	"path"
+
+	semver "github.com/hashicorp/go-version"
+)
+
+// HTTPManifestChecker is a Checker for releases listed in a release manifest (see
+// releaseManifest) published on any web server, like nginx or an S3-compatible bucket.
+type HTTPManifestChecker struct {
+	versionSet
+	downloader
+	client      *http.Client
+	manifestURL string
+	assetURL    string
+	asset       *manifestAsset
+}
+
+// NewHTTPManifestChecker discovers what is the latest version from the release manifest
+// at manifestURL
+//
+// If client is nil, http.DefaultClient is used.
+//
+// If policy pins or caps versions, the pinned (or maximal allowed) release is taken as
+// latest instead. Releases policy does not allow (revoked, or outside the version
+// constraint) are never taken as latest: the newest allowed release below them is.
+//
+// It already saves asset information, leaving everything ready for calling Download().
+// Failing to get it in time (before ctx is done) leaves the latest version unknown.
+func NewHTTPManifestChecker(ctx context.Context, client *http.Client, manifestURL string, policy Policy, current string) (*HTTPManifestChecker, error) {
+	var err error
+	hmc := HTTPManifestChecker{}
+
+	if manifestURL == "" {
+		return nil, errors.New("error getting latest release, manifest url is unset")
+	}
+	hmc.manifestURL = manifestURL
+
+	hmc.versionSet, err = newVersionSet(policy, current)
+	if err != nil {
+		return nil, err
+	}
+
+	if client == nil {
+		hmc.client = http.DefaultClient
+	} else {
+		hmc.client = client
+	}
+
+	m, err := hmc.getManifest(ctx)
+	if err != nil {
+		// We won't error if we can't get the latest version.
+		// Instead we will proceed without the latest version (and assets) info
+		hmc.latestErr = fmt.Errorf("error getting release manifest %s: %s", manifestURL, err)
+		log.Printf("%s. Will ignore and continue with latest version as unknown", hmc.latestErr)
+		return &hmc, nil
+	}
+
+	latest, latestV, err := m.target(&hmc.versionSet)
+	if err != nil {
+		// Same as above: no usable (or allowed) release leaves the latest version unknown
+		hmc.latestErr = fmt.Errorf("error getting latest release from manifest %s: %w", manifestURL, err)
+		log.Printf("%s. Will ignore and continue with latest version as unknown", hmc.latestErr)
+		return &hmc, nil
+	}
+	hmc.latest = latestV
+	hmc.release.URL = manifestURL
+
+	asset, err := latest.platformAsset()
+	if err != nil {
+		log.Printf("error picking release asset from manifest %s: %s", manifestURL, err)
+		return &hmc, nil
+	}
+	hmc.asset = asset
+	hmc.assetURL, err = resolveURL(manifestURL, asset.URL)
+	if err != nil {
+		return nil, err
+	}
+	hmc.release.Asset = urlFileName(hmc.assetURL)
+
+	return &hmc, nil
+}
+
+// resolveURL resolves ref (that may be relative) against base
+func resolveURL(base string, ref string) (string, error) {
+	b, err := url.Parse(base)
+	if err != nil {
+		return "", err
+	}
+
+	r, err := url.Parse(ref)
+	if err != nil {
+		return "", fmt.Errorf("invalid asset url %q in release manifest: %w", ref, err)
+	}
+
+	return b.ResolveReference(r).String(), nil
+}
+
+// urlFileName returns the file name in the path of rawURL, leaving out any query (like
+// the signature of a presigned URL) or fragment
+func urlFileName(rawURL string) string {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return path.Base(rawURL)
+	}
+
+	return path.Base(u.Path)
+}
+
+// getManifest fetches and parses the release manifest
+func (c *HTTPManifestChecker) getManifest(ctx context.Context) (*releaseManifest, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, c.manifestURL, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	resp, err := c.client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("unexpected status %s", resp.Status)
+	}
+
+	data, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, err
+	}
+
+	return parseManifest(data)
+}
+
+// DownloadLatest downloads the saved release asset to a temporary file, verifying its
+// size and checksum if the manifest has them
+func (c *HTTPManifestChecker) DownloadLatest(ctx context.Context) (*Asset, error) {
+	if c == nil {
+		return nil, fmt.Errorf("in HTTPManifestChecker.DownloadLatest: called with nil receiver")
+	}
+
+	if c.asset == nil || c.assetURL == "" {
+		return nil, errors.New("in Download: release asset information is unavailable")
+	}
+
+	return c.download(ctx, c.asset, c.assetURL)
+}
+
+// DownloadVersion downloads the release asset of version v to a temporary file,
+// verifying its size and checksum if the manifest has them
+func (c *HTTPManifestChecker) DownloadVersion(ctx context.Context, v string) (*Asset, error) {
+	if c == nil {
+		return nil, fmt.Errorf("in HTTPManifestChecker.DownloadVersion: called with nil receiver")
+	}
+
+	asset, err := c.versionAsset(ctx, v)
+	if err != nil {
+		return nil, err
+	}
+
+	assetURL, err := resolveURL(c.manifestURL, asset.URL)
+	if err != nil {
+		return nil, fmt.Errorf("in Download: %w", err)
+	}
+
+	return c.download(ctx, asset, assetURL)
+}
+
+// DownloadPatch downloads the patch from the current version to version v to a
+// temporary file, verifying its size and checksum if the manifest has them
+func (c *HTTPManifestChecker) DownloadPatch(ctx context.Context, v string) (*Asset, error) {
+	if c == nil {
+		return nil, fmt.Errorf("in HTTPManifestChecker.DownloadPatch: called with nil receiver")
+	}
+
+	asset := c.asset
+	if !c.isLatest(v) || asset == nil {
+		var err error
+		asset, err = c.versionAsset(ctx, v)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	patch := asset.patchFrom(c.current)
+	if patch == nil {
+		return nil, ErrNoPatch
+	}
+
+	patchURL, err := resolveURL(c.manifestURL, patch.URL)
+	if err != nil {
+		return nil, fmt.Errorf("in Download: %w", err)
+	}
+
+	return patched(c.download(ctx, patch, patchURL))
+}
+
+// versionAsset returns the release asset of version v, from a freshly fetched manifest
+func (c *HTTPManifestChecker) versionAsset(ctx context.Context, v string) (*manifestAsset, error) {
+	want, err := semver.NewSemver(v)
+	if err != nil {
+		return nil, fmt.Errorf("in Download: %w", err)
+	}
+
+	m, err := c.getManifest(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("in Download: error getting release manifest %s: %w", c.manifestURL, err)
+	}
+
+	release, _, err := m.release(want)
+	if err != nil {
+		return nil, fmt.Errorf("in Download: %w", err)
+	}
+
+	asset, err := release.platformAsset()
+	if err != nil {
+		return nil, fmt.Errorf("in Download: %w", err)
+	}
+
+	return asset, nil
+}
+
+// download downloads asset from assetURL (and its signature, if any) to temporary files,
+// verifying it
+func (c *HTTPManifestChecker) download(ctx context.Context, asset *manifestAsset, assetURL string) (*Asset, error) {
+	filename, err := c.downloadURL(ctx, c.client, assetURL, urlFileName(assetURL))
+	if err != nil {
+		return nil, err
+	}
+
+	a, err := checkedAsset(filename, asset.Size, asset.SHA256, asset.BinarySHA256)
+	if err != nil {
+		return nil, err
+	}
+
+	if asset.Signature != "" {
+		signatureURL, err := resolveURL(c.manifestURL, asset.Signature)
+		if err != nil {
+			return nil, fmt.Errorf("in Download: %w", err)
+		}
+
+		a.Signature, err = c.downloadURL(ctx, c.client, signatureURL, urlFileName(signatureURL))
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return a, nil
+}
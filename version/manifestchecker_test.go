@@ -0,0 +1,573 @@
+package version_test
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/dgmorales/go-cli-selfupdate/version"
+)
+
+func fakeAssetSHA256() string {
+	sum := sha256.Sum256([]byte(fakeAssetContent))
+	return hex.EncodeToString(sum[:])
+}
+
+// yamlManifest returns a YAML release manifest with releases for the given versions,
+// each with an asset for our platform
+func yamlManifest(sha256sum string, versions ...string) string {
+	m := "schemaVersion: 1\nreleases:\n"
+	for _, v := range versions {
+		m += fmt.Sprintf(`  - version: %s
+    date: 2022-12-01T10:00:00Z
+    assets:
+      - os: %s
+        arch: %s
+        url: %s/test-%s.tar.gz
+        size: %d
+        sha256: %s
+`, v, runtime.GOOS, runtime.GOARCH, v, v, len(fakeAssetContent), sha256sum)
+	}
+	return m
+}
+
+// newManifestServer serves manifest at /releases/manifest.yaml, and fakeAssetContent on
+// any other path
+func newManifestServer(t *testing.T, manifest string) *httptest.Server {
+	t.Helper()
+
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/releases/manifest.yaml" {
+			if manifest == "" {
+				http.NotFound(w, r)
+				return
+			}
+			fmt.Fprint(w, manifest)
+			return
+		}
+		fmt.Fprint(w, fakeAssetContent)
+	}))
+	t.Cleanup(srv.Close)
+
+	return srv
+}
+
+func TestHTTPManifestCheckerImplementsChecker(t *testing.T) {
+	var i interface{} = new(version.HTTPManifestChecker)
+	if _, ok := i.(version.Checker); !ok {
+		t.Fatalf("expected %T to implement version.Checker", i)
+	}
+}
+
+func TestNewHTTPManifestChecker(t *testing.T) {
+	testCases := []struct {
+		desc     string
+		manifest string
+		spec     versionsCaseSpec
+	}{
+		{
+			desc:     "PicksHighestVersionFromYAML",
+			manifest: yamlManifest(fakeAssetSHA256(), "v1.0.0", "v2.1.0", "v2.0.0"),
+			spec:     versionsCaseSpec{min: "1.0.0", cur: "v2.0.0", latest: "v2.1.0"},
+		},
+		{
+			desc: "ReadsJSON",
+			manifest: fmt.Sprintf(`{"schemaVersion": 1, "releases": [{"version": "v3.0.1", "assets": [{"os": "%s", "url": "test.zip"}]}]}`,
+				runtime.GOOS),
+			spec: versionsCaseSpec{min: "", cur: "v2.0.0", latest: "v3.0.1"},
+		},
+		{
+			desc:     "IgnoresPrereleases",
+			manifest: yamlManifest(fakeAssetSHA256(), "v2.1.0", "v2.2.0-rc.1"),
+			spec:     versionsCaseSpec{min: "1.0.0", cur: "v2.0.0", latest: "v2.1.0"},
+		},
+		{
+			desc:     "LatestIsUnknownWithUnsupportedSchemaVersion",
+			manifest: "schemaVersion: 2\nreleases: []\n",
+			spec:     versionsCaseSpec{min: "1.0.0", cur: "v2.0.0", latest: ""},
+		},
+		{
+			desc:     "LatestIsUnknownWhenManifestIsMissing",
+			manifest: "",
+			spec:     versionsCaseSpec{min: "1.0.0", cur: "v2.0.0", latest: ""},
+		},
+	}
+	for _, tC := range testCases {
+		t.Run(tC.desc, func(t *testing.T) {
+			srv := newManifestServer(t, tC.manifest)
+
+			mc, err := version.NewHTTPManifestChecker(context.Background(), srv.Client(), srv.URL+"/releases/manifest.yaml", version.Policy{MinimalRequired: tC.spec.min}, tC.spec.cur)
+			if err != nil {
+				t.Fatalf("expected nil error, got %s", err)
+			}
+
+			verifyExpectedVersions(t, mc, tC.spec)
+		})
+	}
+}
+
+func TestNewHTTPManifestCheckerSkipsRevokedReleases(t *testing.T) {
+	srv := newManifestServer(t, yamlManifest(fakeAssetSHA256(), "v1.0.0", "v2.0.0", "v2.1.0", "v2.2.0"))
+
+	mc, err := version.NewHTTPManifestChecker(context.Background(), srv.Client(), srv.URL+"/releases/manifest.yaml",
+		version.Policy{Revoked: []string{"2.2.0", "2.1.0"}}, "v1.0.0")
+	if err != nil {
+		t.Fatalf("expected nil error, got %s", err)
+	}
+
+	verifyExpectedVersions(t, mc, versionsCaseSpec{cur: "v1.0.0", latest: "v2.0.0"})
+	if ans := mc.Check(); ans != version.CanUpdate {
+		t.Errorf("expected '%d/%s', got '%d/%s'", version.CanUpdate, assertStr(version.CanUpdate), ans, assertStr(ans))
+	}
+}
+
+func TestNewHTTPManifestCheckerSkipsBadReleases(t *testing.T) {
+	testCases := []struct {
+		desc     string
+		manifest string
+		latest   string
+	}{
+		{
+			desc:     "SkipsInvalidVersion",
+			manifest: yamlManifest(fakeAssetSHA256(), "v1.0.0", "silver"),
+			latest:   "v1.0.0",
+		},
+		{
+			desc:     "LatestIsUnknownWithOnlyInvalidVersions",
+			manifest: yamlManifest(fakeAssetSHA256(), "silver", "gold"),
+		},
+		{
+			desc:     "LatestIsUnknownWithNoReleases",
+			manifest: "schemaVersion: 1\nreleases: []\n",
+		},
+	}
+	for _, tC := range testCases {
+		t.Run(tC.desc, func(t *testing.T) {
+			srv := newManifestServer(t, tC.manifest)
+
+			mc, err := version.NewHTTPManifestChecker(context.Background(), srv.Client(), srv.URL+"/releases/manifest.yaml", version.Policy{MinimalRequired: "1.0.0"}, "v2.0.0")
+			if err != nil {
+				t.Fatalf("expected nil error, got %s", err)
+			}
+
+			verifyExpectedVersions(t, mc, versionsCaseSpec{min: "1.0.0", cur: "v2.0.0", latest: tC.latest})
+			if tC.latest == "" && mc.LatestErr() == nil {
+				t.Error("expected error on why latest version is unknown, got nil")
+			}
+		})
+	}
+}
+
+func TestNewHTTPManifestCheckerPicksPlatformAsset(t *testing.T) {
+	testCases := []struct {
+		desc   string
+		assets string
+		asset  string // blank if none should be picked
+	}{
+		{
+			desc:   "PrefersExactArchOverAnyArch",
+			assets: `{"os": "%[1]s", "url": "any.tar.gz"}, {"os": "%[1]s", "arch": "%[2]s", "url": "exact.tar.gz"}`,
+			asset:  "exact.tar.gz",
+		},
+		{
+			desc:   "TakesAnyArchWithoutExactArch",
+			assets: `{"os": "%[1]s", "arch": "other", "url": "other.tar.gz"}, {"os": "%[1]s", "url": "any.tar.gz"}`,
+			asset:  "any.tar.gz",
+		},
+		{
+			desc:   "PicksNoneWhenAmbiguous",
+			assets: `{"os": "%[1]s", "arch": "%[2]s", "url": "one.tar.gz"}, {"os": "%[1]s", "arch": "%[2]s", "url": "two.tar.gz"}`,
+		},
+	}
+	for _, tC := range testCases {
+		t.Run(tC.desc, func(t *testing.T) {
+			assets := fmt.Sprintf(tC.assets, runtime.GOOS, runtime.GOARCH)
+			srv := newManifestServer(t, `{"schemaVersion": 1, "releases": [{"version": "v3.0.0", "assets": [`+assets+`]}]}`)
+
+			mc, err := version.NewHTTPManifestChecker(context.Background(), srv.Client(), srv.URL+"/releases/manifest.yaml", version.Policy{}, "v2.0.0")
+			if err != nil {
+				t.Fatalf("expected nil error, got %s", err)
+			}
+
+			if got := mc.LatestRelease().Asset; got != tC.asset {
+				t.Errorf("expected asset %q, got %q", tC.asset, got)
+			}
+		})
+	}
+}
+
+func TestHTTPManifestCheckerDownloadLatest(t *testing.T) {
+	testCases := []struct {
+		desc       string
+		sha256sum  string
+		shouldFail bool
+	}{
+		{
+			desc:      "WorksWithMatchingChecksum",
+			sha256sum: fakeAssetSHA256(),
+		},
+		{
+			desc:       "FailsWithWrongChecksum",
+			sha256sum:  "badc0ffee",
+			shouldFail: true,
+		},
+	}
+	for _, tC := range testCases {
+		t.Run(tC.desc, func(t *testing.T) {
+			srv := newManifestServer(t, yamlManifest(tC.sha256sum, "v3.0.0"))
+
+			mc, err := version.NewHTTPManifestChecker(context.Background(), srv.Client(), srv.URL+"/releases/manifest.yaml", version.Policy{MinimalRequired: "v1"}, "v2")
+			if err != nil {
+				t.Fatalf("expected nil error, got %s", err)
+			}
+
+			asset, err := mc.DownloadLatest(context.Background())
+			if tC.shouldFail {
+				if err == nil {
+					t.Fatal("expected error on download, got nil")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("expected nil error on download, got %s", err)
+			}
+
+			verifyDownloadedFile(t, asset)
+		})
+	}
+}
+
+func TestHTTPManifestCheckerDownloadVersion(t *testing.T) {
+	srv := newManifestServer(t, yamlManifest(fakeAssetSHA256(), "v2.0.0", "v2.1.0-rc.1", "v3.0.0"))
+
+	mc, err := version.NewHTTPManifestChecker(context.Background(), srv.Client(), srv.URL+"/releases/manifest.yaml", version.Policy{MinimalRequired: "v1"}, "v3.0.0")
+	if err != nil {
+		t.Fatalf("expected nil error, got %s", err)
+	}
+
+	asset, err := mc.DownloadVersion(context.Background(), "2.1.0-rc.1")
+	if err != nil {
+		t.Fatalf("expected nil error on download, got %s", err)
+	}
+
+	verifyDownloadedFile(t, asset)
+
+	_, err = mc.DownloadVersion(context.Background(), "v2.5.0")
+	if err == nil {
+		t.Errorf("expected error downloading missing version, got nil")
+	}
+}
+
+func TestHTTPManifestCheckerDownloadPatch(t *testing.T) {
+	manifest := yamlManifest(fakeAssetSHA256(), "v3.0.0") + fmt.Sprintf(`        binarySha256: %s
+        patches:
+          - from: v1.0.0
+            url: v3.0.0/test-v3.0.0.from-v1.0.0.bsdiff
+          - from: v2.0.0
+            url: v3.0.0/test-v3.0.0.from-v2.0.0.bsdiff
+            sha256: %s
+`, fakeAssetSHA256(), fakeAssetSHA256())
+	srv := newManifestServer(t, manifest)
+
+	testCases := []struct {
+		desc    string
+		cur     string
+		noPatch bool
+	}{
+		{desc: "WithPatchFromCurrentVersion", cur: "2.0.0"},
+		{desc: "WithoutPatchFromCurrentVersion", cur: "v2.5.0", noPatch: true},
+	}
+	for _, tC := range testCases {
+		t.Run(tC.desc, func(t *testing.T) {
+			mc, err := version.NewHTTPManifestChecker(context.Background(), srv.Client(), srv.URL+"/releases/manifest.yaml", version.Policy{}, tC.cur)
+			if err != nil {
+				t.Fatalf("expected nil error, got %s", err)
+			}
+
+			asset, err := mc.DownloadPatch(context.Background(), "v3.0.0")
+			if tC.noPatch {
+				if !errors.Is(err, version.ErrNoPatch) {
+					t.Fatalf("expected ErrNoPatch, got %v", err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("expected nil error on download, got %s", err)
+			}
+
+			if !asset.Patch || !strings.HasSuffix(asset.Filename, "test-v3.0.0.from-v2.0.0.bsdiff") {
+				t.Errorf("expected patch from v2.0.0 to be downloaded, got %+v", asset)
+			}
+		})
+	}
+}
+
+func TestHTTPManifestCheckerResumesDownloads(t *testing.T) {
+	content := strings.Repeat(fakeAssetContent, 100)
+	sum := sha256.Sum256([]byte(content))
+
+	testCases := []struct {
+		desc        string
+		partial     string // left by a previous run
+		interrupt   bool   // the first response
+		ignoreRange bool
+	}{
+		{
+			desc:      "ResumesInterruptedDownload",
+			interrupt: true,
+		},
+		{
+			desc:    "ResumesPartialDownloadOfPreviousRun",
+			partial: content[:500],
+		},
+		{
+			desc:        "StartsOverIfServerIgnoresRange",
+			partial:     "garbage from a previous run",
+			ignoreRange: true,
+		},
+	}
+	for _, tC := range testCases {
+		t.Run(tC.desc, func(t *testing.T) {
+			setDownloadDir(t)
+			defer version.SetResumeDelay(version.SetResumeDelay(0))
+
+			manifest := fmt.Sprintf("schemaVersion: 1\nreleases:\n  - version: v3.0.0\n    assets:\n      - os: %s\n        url: test-v3.0.0.tar.gz\n        size: %d\n        sha256: %x\n",
+				runtime.GOOS, len(content), sum)
+
+			requests, ranges := 0, 0
+			srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				if r.URL.Path == "/releases/manifest.yaml" {
+					fmt.Fprint(w, manifest)
+					return
+				}
+
+				requests++
+				if r.Header.Get("Range") != "" {
+					ranges++
+				}
+				if tC.interrupt && requests == 1 {
+					// promise it all, but drop the connection halfway
+					w.Header().Set("Content-Length", fmt.Sprint(len(content)))
+					fmt.Fprint(w, content[:len(content)/2])
+					w.(http.Flusher).Flush()
+					panic(http.ErrAbortHandler)
+				}
+				if tC.ignoreRange {
+					fmt.Fprint(w, content)
+					return
+				}
+				http.ServeContent(w, r, "test-v3.0.0.tar.gz", time.Time{}, strings.NewReader(content))
+			}))
+			t.Cleanup(srv.Close)
+
+			if tC.partial != "" {
+				err := os.MkdirAll(version.DownloadDir, 0o700)
+				if err != nil {
+					t.Fatal(err)
+				}
+				part := filepath.Join(version.DownloadDir, "test-v3.0.0.tar.gz.part")
+				err = os.WriteFile(part, []byte(tC.partial), 0o600)
+				if err != nil {
+					t.Fatal(err)
+				}
+			}
+
+			mc, err := version.NewHTTPManifestChecker(context.Background(), srv.Client(), srv.URL+"/releases/manifest.yaml", version.Policy{}, "v2.0.0")
+			if err != nil {
+				t.Fatalf("expected nil error, got %s", err)
+			}
+
+			var done, total int64
+			mc.SetProgress(func(name string, d int64, t int64) {
+				done, total = d, t
+			})
+
+			asset, err := mc.DownloadLatest(context.Background())
+			if err != nil {
+				t.Fatalf("expected nil error on download, got %s", err)
+			}
+
+			data, err := os.ReadFile(asset.Filename)
+			if err != nil || string(data) != content {
+				t.Errorf("expected downloaded file with the asset content, got %d bytes (err: %v)", len(data), err)
+			}
+			if ranges != 1 {
+				t.Errorf("expected 1 range request, got %d", ranges)
+			}
+			if done != int64(len(content)) || total != int64(len(content)) {
+				t.Errorf("expected final progress %d/%d, got %d/%d", len(content), len(content), done, total)
+			}
+		})
+	}
+}
+
+// setDownloadDir makes releases be downloaded to a temporary dir during test t
+func setDownloadDir(t *testing.T) {
+	t.Helper()
+
+	old := version.DownloadDir
+	version.DownloadDir = filepath.Join(t.TempDir(), "downloads")
+	t.Cleanup(func() { version.DownloadDir = old })
+}
+
+func TestHTTPManifestCheckerKeepsDownloadsPrivate(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("file modes do not tell who can access files on windows")
+	}
+	setDownloadDir(t)
+
+	err := os.MkdirAll(version.DownloadDir, 0o755)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// a partial download left readable by others, by an older version
+	err = os.WriteFile(filepath.Join(version.DownloadDir, "test-v3.0.0.tar.gz.part"), []byte(fakeAssetContent[:5]), 0o644)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	srv := newManifestServer(t, yamlManifest(fakeAssetSHA256(), "v3.0.0"))
+	mc, err := version.NewHTTPManifestChecker(context.Background(), srv.Client(), srv.URL+"/releases/manifest.yaml", version.Policy{}, "v2.0.0")
+	if err != nil {
+		t.Fatalf("expected nil error, got %s", err)
+	}
+
+	asset, err := mc.DownloadLatest(context.Background())
+	if err != nil {
+		t.Fatalf("expected nil error on download, got %s", err)
+	}
+
+	for filename, mode := range map[string]os.FileMode{version.DownloadDir: 0o700, asset.Filename: 0o600} {
+		info, err := os.Stat(filename)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if info.Mode().Perm() != mode {
+			t.Errorf("expected %s with mode %s, got %s", filename, mode, info.Mode().Perm())
+		}
+	}
+}
+
+func TestNewHTTPManifestCheckerGivesUpWhenContextIsDone(t *testing.T) {
+	// a server that never answers
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		<-r.Context().Done()
+	}))
+	t.Cleanup(srv.Close)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+
+	mc, err := version.NewHTTPManifestChecker(ctx, srv.Client(), srv.URL+"/releases/manifest.yaml", version.Policy{}, "v2.0.0")
+	if err != nil {
+		t.Fatalf("expected nil error, got %s", err)
+	}
+
+	if ans := mc.Check(); ans != version.IsUnknown {
+		t.Errorf("expected '%d/%s', got '%d/%s'", version.IsUnknown, assertStr(version.IsUnknown), ans, assertStr(ans))
+	}
+}
+
+func TestHTTPManifestCheckerDownloadStopsWhenCanceled(t *testing.T) {
+	setDownloadDir(t)
+
+	content := strings.Repeat("0123456789", 100)
+	manifest := fmt.Sprintf("schemaVersion: 1\nreleases:\n  - version: v3.0.0\n    assets:\n      - os: %s\n        url: test-v3.0.0.tar.gz\n",
+		runtime.GOOS)
+
+	downloads := 0
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/releases/manifest.yaml" {
+			fmt.Fprint(w, manifest)
+			return
+		}
+
+		// send half of it, then hang
+		downloads++
+		w.Header().Set("Content-Length", fmt.Sprint(len(content)))
+		fmt.Fprint(w, content[:len(content)/2])
+		w.(http.Flusher).Flush()
+		<-r.Context().Done()
+	}))
+	t.Cleanup(srv.Close)
+
+	mc, err := version.NewHTTPManifestChecker(context.Background(), srv.Client(), srv.URL+"/releases/manifest.yaml", version.Policy{}, "v2.0.0")
+	if err != nil {
+		t.Fatalf("expected nil error, got %s", err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	mc.SetProgress(func(name string, done int64, total int64) {
+		if done > 0 {
+			cancel()
+		}
+	})
+
+	_, err = mc.DownloadLatest(ctx)
+	if !errors.Is(err, context.Canceled) {
+		t.Fatalf("expected error matching context.Canceled, got %v", err)
+	}
+	if downloads != 1 {
+		t.Errorf("expected canceled download not to be resumed, got %d requests", downloads)
+	}
+
+	part := filepath.Join(version.DownloadDir, "test-v3.0.0.tar.gz.part")
+	info, err := os.Stat(part)
+	if err != nil || info.Size() == 0 {
+		t.Fatalf("expected partial download kept for resuming, got %v", err)
+	}
+	if runtime.GOOS != "windows" && info.Mode().Perm() != 0o600 {
+		t.Errorf("expected partial download only readable by us, got mode %s", info.Mode())
+	}
+}
+
+func TestHTTPManifestCheckerLatestRelease(t *testing.T) {
+	srv := newManifestServer(t, yamlManifest(fakeAssetSHA256(), "v2.0.0", "v3.0.0"))
+	manifestURL := srv.URL + "/releases/manifest.yaml"
+
+	mc, err := version.NewHTTPManifestChecker(context.Background(), srv.Client(), manifestURL, version.Policy{MinimalRequired: "v1"}, "v2")
+	if err != nil {
+		t.Fatalf("expected nil error, got %s", err)
+	}
+
+	exp := version.ReleaseInfo{Asset: "test-v3.0.0.tar.gz", URL: manifestURL}
+	if r := mc.LatestRelease(); r != exp {
+		t.Errorf("expected latest release %+v, got %+v", exp, r)
+	}
+}
+
+func TestHTTPManifestCheckerNamesAssetsWithoutURLQuery(t *testing.T) {
+	setDownloadDir(t)
+
+	manifest := fmt.Sprintf("schemaVersion: 1\nreleases:\n  - version: v3.0.0\n    assets:\n      - os: %s\n        url: test-v3.0.0.tar.gz?X-Amz-Signature=abc123&X-Amz-Expires=3600\n        sha256: %s\n",
+		runtime.GOOS, fakeAssetSHA256())
+	srv := newManifestServer(t, manifest)
+
+	mc, err := version.NewHTTPManifestChecker(context.Background(), srv.Client(), srv.URL+"/releases/manifest.yaml", version.Policy{}, "v2.0.0")
+	if err != nil {
+		t.Fatalf("expected nil error, got %s", err)
+	}
+
+	if r := mc.LatestRelease(); r.Asset != "test-v3.0.0.tar.gz" {
+		t.Errorf("expected latest release asset test-v3.0.0.tar.gz, got %s", r.Asset)
+	}
+
+	asset, err := mc.DownloadLatest(context.Background())
+	if err != nil {
+		t.Fatalf("expected nil error on download, got %s", err)
+	}
+	if filepath.Base(asset.Filename) != "test-v3.0.0.tar.gz" {
+		t.Errorf("expected asset downloaded to test-v3.0.0.tar.gz, got %s", asset.Filename)
+	}
+}
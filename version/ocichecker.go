@@ -0,0 +1,411 @@
+package version
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"net/url"
+	"path"
+	"runtime"
+	"strings"
+
+	semver "github.com/hashicorp/go-version"
+)
+
+// OCI (and docker) media types we know how to handle
+const (
+	mediaTypeOCIIndex    = "application/vnd.oci.image.index.v1+json"
+	mediaTypeOCIManifest = "application/vnd.oci.image.manifest.v1+json"
+	mediaTypeDockerList  = "application/vnd.docker.distribution.manifest.list.v2+json"
+	mediaTypeDockerImage = "application/vnd.docker.distribution.manifest.v2+json"
+)
+
+// ociManifestAcceptTypes is the Accept header value for getting manifests
+const ociManifestAcceptTypes = mediaTypeOCIIndex + ", " + mediaTypeOCIManifest + ", " + mediaTypeDockerList + ", " + mediaTypeDockerImage
+
+// annotationTitle is the layer annotation with its file name
+const annotationTitle = "org.opencontainers.image.title"
+
+// maxTagListPages limits tag list pagination, in case a registry keeps sending us around
+const maxTagListPages = 100
+
+// OCIChecker is a Checker for releases published as OCI artifacts in a container
+// registry.
+//
+// Each release is a tag named after its version. The tag may point to an image index
+// with one manifest per platform (os/architecture), or to a single manifest with one
+// layer per platform. In the later case, layers are picked by their title annotation
+// (the file name, as set by tools like oras), just like assets of other release sources.
+// Then, a layer titled after the binary plus .minisig is taken as its signature.
+type OCIChecker struct {
+	versionSet
+	downloader
+	client      *http.Client
+	registryURL string
+	repository  string
+	asset       *ociAsset
+}
+
+// ociAsset is the blob with the release asset for our platform, along with the blob with
+// the binary signature, if any
+type ociAsset struct {
+	name      string
+	blob      *ociDescriptor
+	signature *ociDescriptor
+}
+
+// ociDescriptor describes content in an OCI registry
+type ociDescriptor struct {
+	MediaType   string            `json:"mediaType"`
+	Digest      string            `json:"digest"`
+	Size        int64             `json:"size"`
+	Annotations map[string]string `json:"annotations,omitempty"`
+	Platform    *struct {
+		OS           string `json:"os"`
+		Architecture string `json:"architecture"`
+	} `json:"platform,omitempty"`
+}
+
+// ociManifest is either an image index (with Manifests) or an image manifest (with
+// Layers)
+type ociManifest struct {
+	MediaType string          `json:"mediaType"`
+	Manifests []ociDescriptor `json:"manifests"`
+	Layers    []ociDescriptor `json:"layers"`
+}
+
+// NewOCIChecker discovers what is the latest version from the tags of repository in
+// the OCI registry at registryURL (like https://registry.example.com).
+//
+// client should be authenticated for private repositories (see oci.NewClient). If nil,
+// http.DefaultClient is used.
+//
+// If policy pins or caps versions, the pinned (or maximal allowed) release is taken as
+// latest instead. Releases policy does not allow (revoked, or outside the version
+// constraint) are never taken as latest: the newest allowed release below them is.
+//
+// It already saves asset information, leaving everything ready for calling Download().
+// Failing to get it in time (before ctx is done) leaves the latest version unknown.
+func NewOCIChecker(ctx context.Context, client *http.Client, registryURL string, repository string, policy Policy, current string) (*OCIChecker, error) {
+	var err error
+	oc := OCIChecker{}
+
+	if registryURL == "" || repository == "" {
+		return nil, errors.New("error getting latest oci release, registry or repository are unset")
+	}
+	oc.registryURL = strings.TrimRight(registryURL, "/")
+	oc.repository = strings.Trim(repository, "/")
+
+	oc.versionSet, err = newVersionSet(policy, current)
+	if err != nil {
+		return nil, err
+	}
+
+	if client == nil {
+		oc.client = http.DefaultClient
+	} else {
+		oc.client = client
+	}
+
+	tags, err := oc.listTags(ctx)
+	if err != nil {
+		// We won't error if we can't get the latest version.
+		// Instead we will proceed without the latest version (and assets) info
+		oc.latestErr = fmt.Errorf("error listing tags from %s/%s: %s", oc.registryURL, oc.repository, err)
+		log.Printf("%s. Will ignore and continue with latest version as unknown", oc.latestErr)
+		return &oc, nil
+	}
+
+	latestTag := ""
+	versionTags := map[string]*semver.Version{}
+	for _, tag := range tags {
+		// ignore tags not named as a version (like "latest"). Prereleases are never latest,
+		// but may be pinned.
+		v, err := semver.NewSemver(tag)
+		if err != nil {
+			continue
+		}
+		versionTags[tag] = v
+
+		if v.Prerelease() == "" && (oc.latest == nil || v.GreaterThan(oc.latest)) {
+			oc.latest, latestTag = v, tag
+		}
+	}
+
+	if want := oc.limit(oc.latest); want != nil {
+		oc.latest, latestTag = nil, ""
+		for tag, v := range versionTags {
+			if v.Equal(want) {
+				oc.latest, latestTag = v, tag
+			}
+		}
+	}
+
+	if oc.pinned == nil && oc.latest != nil && !oc.allowed(oc.latest) {
+		// fall back to the newest (non-prerelease) release policy allows
+		fallbackTags := []string{}
+		versions := []*semver.Version{}
+		for tag, v := range versionTags {
+			if v.Prerelease() == "" {
+				fallbackTags, versions = append(fallbackTags, tag), append(versions, v)
+			}
+		}
+
+		i := oc.fallback(oc.latest, versions)
+		if i < 0 {
+			oc.latestErr = fmt.Errorf("error getting latest release from %s/%s: %w (%s is not)",
+				oc.registryURL, oc.repository, errNoAllowedRelease, oc.latest)
+			log.Printf("%s. Will ignore and continue with latest version as unknown", oc.latestErr)
+			oc.latest = nil
+			return &oc, nil
+		}
+		oc.latest, latestTag = versions[i], fallbackTags[i]
+	}
+
+	if oc.latest == nil {
+		log.Printf("no release tags in %s/%s. Will continue with latest version as unknown", oc.registryURL, oc.repository)
+		return &oc, nil
+	}
+
+	// the artifact reference, as users would pull it
+	registry := oc.registryURL
+	if u, err := url.Parse(oc.registryURL); err == nil && u.Host != "" {
+		registry = u.Host
+	}
+	oc.release.URL = fmt.Sprintf("%s/%s:%s", registry, oc.repository, latestTag)
+
+	oc.asset, err = oc.resolveBlob(ctx, latestTag)
+	if err != nil {
+		log.Printf("error resolving release artifact %s/%s:%s: %s. Will continue without asset information",
+			oc.registryURL, oc.repository, latestTag, err)
+	}
+	if oc.asset != nil {
+		oc.release.Asset = oc.asset.name
+	}
+
+	return &oc, nil
+}
+
+// getJSON gets the registry API path p, decoding its JSON response into v.
+//
+// It returns the response headers, for callers that need them.
+func (c *OCIChecker) getJSON(ctx context.Context, p string, accept string, v interface{}) (http.Header, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, c.registryURL+p, nil)
+	if err != nil {
+		return nil, err
+	}
+	if accept != "" {
+		req.Header.Set("Accept", accept)
+	}
+
+	resp, err := c.client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("unexpected status %s getting %s", resp.Status, p)
+	}
+
+	err = json.NewDecoder(resp.Body).Decode(v)
+	if err != nil {
+		return nil, fmt.Errorf("error parsing %s: %w", p, err)
+	}
+
+	return resp.Header, nil
+}
+
+// listTags lists all tags in the repository, following pagination
+func (c *OCIChecker) listTags(ctx context.Context) ([]string, error) {
+	tags := []string{}
+	next := fmt.Sprintf("/v2/%s/tags/list", c.repository)
+
+	for i := 0; next != "" && i < maxTagListPages; i++ {
+		var page struct {
+			Tags []string `json:"tags"`
+		}
+
+		h, err := c.getJSON(ctx, next, "", &page)
+		if err != nil {
+			return nil, err
+		}
+		tags = append(tags, page.Tags...)
+
+		next = nextPage(h.Get("Link"))
+	}
+
+	return tags, nil
+}
+
+// nextPage returns the next page path from a Link header like:
+//
+//	</v2/team/cli/tags/list?n=100&last=v0.3.3>; rel="next"
+func nextPage(link string) string {
+	if !strings.Contains(link, `rel="next"`) {
+		return ""
+	}
+
+	start, end := strings.Index(link, "<"), strings.Index(link, ">")
+	if start < 0 || end < start {
+		return ""
+	}
+
+	u, err := url.Parse(link[start+1 : end])
+	if err != nil {
+		return ""
+	}
+
+	return u.RequestURI()
+}
+
+// resolveBlob finds the blob with the release asset for our platform, tagged as tag
+func (c *OCIChecker) resolveBlob(ctx context.Context, tag string) (*ociAsset, error) {
+	m := ociManifest{}
+	_, err := c.getJSON(ctx, fmt.Sprintf("/v2/%s/manifests/%s", c.repository, tag), ociManifestAcceptTypes, &m)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(m.Manifests) > 0 {
+		var platformManifest *ociDescriptor
+		for i, d := range m.Manifests {
+			if d.Platform != nil && d.Platform.OS == runtime.GOOS && d.Platform.Architecture == runtime.GOARCH {
+				platformManifest = &m.Manifests[i]
+				break
+			}
+		}
+		if platformManifest == nil {
+			return nil, fmt.Errorf("no manifest for %s/%s", runtime.GOOS, runtime.GOARCH)
+		}
+
+		m = ociManifest{}
+		_, err = c.getJSON(ctx, fmt.Sprintf("/v2/%s/manifests/%s", c.repository, platformManifest.Digest), ociManifestAcceptTypes, &m)
+		if err != nil {
+			return nil, err
+		}
+
+		if len(m.Layers) == 1 {
+			return &ociAsset{name: c.blobName(tag, &m.Layers[0]), blob: &m.Layers[0]}, nil
+		}
+	}
+
+	names := make([]string, len(m.Layers))
+	for i, l := range m.Layers {
+		names[i] = l.Annotations[annotationTitle]
+	}
+	// tags are named after versions (or we would not be here)
+	v, _ := semver.NewSemver(tag)
+	i, err := c.platformAsset(names, v)
+	if err != nil {
+		return nil, fmt.Errorf("error picking layer: %w", err)
+	}
+
+	asset := ociAsset{name: c.blobName(tag, &m.Layers[i]), blob: &m.Layers[i]}
+	if i := signatureAsset(names, asset.name); i >= 0 {
+		asset.signature = &m.Layers[i]
+	}
+
+	return &asset, nil
+}
+
+// blobName names blob, tagged as tag, after its title or its media type
+func (c *OCIChecker) blobName(tag string, blob *ociDescriptor) string {
+	name := blob.Annotations[annotationTitle]
+
+	if name == "" {
+		name = fmt.Sprintf("%s-%s-%s-%s", path.Base(c.repository), tag, runtime.GOOS, runtime.GOARCH)
+		switch {
+		case strings.HasSuffix(blob.MediaType, "tar+gzip"):
+			name += ".tar.gz"
+		case strings.HasSuffix(blob.MediaType, "zip"):
+			name += ".zip"
+		}
+	}
+
+	return name
+}
+
+// DownloadLatest downloads the saved release blob to a temporary file, verifying its
+// size and digest
+func (c *OCIChecker) DownloadLatest(ctx context.Context) (*Asset, error) {
+	if c == nil {
+		return nil, fmt.Errorf("in OCIChecker.DownloadLatest: called with nil receiver")
+	}
+
+	if c.asset == nil {
+		return nil, errors.New("in Download: oci release asset information is unavailable")
+	}
+
+	return c.download(ctx, c.asset)
+}
+
+// DownloadVersion downloads the release blob of version v to a temporary file,
+// verifying its size and digest. The release is looked up by tag name.
+func (c *OCIChecker) DownloadVersion(ctx context.Context, v string) (*Asset, error) {
+	if c == nil {
+		return nil, fmt.Errorf("in OCIChecker.DownloadVersion: called with nil receiver")
+	}
+
+	want, err := semver.NewSemver(v)
+	if err != nil {
+		return nil, fmt.Errorf("in Download: %w", err)
+	}
+
+	for _, tag := range releaseTags(want) {
+		var asset *ociAsset
+
+		asset, err = c.resolveBlob(ctx, tag)
+		if err == nil {
+			return c.download(ctx, asset)
+		}
+	}
+
+	return nil, fmt.Errorf("in Download: error resolving release artifact %s/%s:%s: %w",
+		c.registryURL, c.repository, v, err)
+}
+
+// download downloads asset (and its signature, if any) to temporary files, verifying
+// their sizes and digests
+func (c *OCIChecker) download(ctx context.Context, asset *ociAsset) (*Asset, error) {
+	a, err := c.downloadBlob(ctx, asset.name, asset.blob)
+	if err != nil {
+		return nil, err
+	}
+
+	if asset.signature != nil {
+		signature, err := c.downloadBlob(ctx, signatureName(asset.name), asset.signature)
+		if err != nil {
+			return nil, err
+		}
+		a.Signature = signature.Filename
+	}
+
+	return a, nil
+}
+
+// DownloadPatch always returns ErrNoPatch: OCI artifacts have no binary checksums to
+// verify patched binaries with
+func (c *OCIChecker) DownloadPatch(ctx context.Context, v string) (*Asset, error) {
+	return nil, ErrNoPatch
+}
+
+// downloadBlob downloads blob to a temporary file named after name, verifying its size
+// and digest
+func (c *OCIChecker) downloadBlob(ctx context.Context, name string, blob *ociDescriptor) (*Asset, error) {
+	algorithm, digest, _ := strings.Cut(blob.Digest, ":")
+	if algorithm != "sha256" {
+		return nil, fmt.Errorf("in Download: unsupported blob digest %s", blob.Digest)
+	}
+
+	filename, err := c.downloadURL(ctx, c.client,
+		fmt.Sprintf("%s/v2/%s/blobs/%s", c.registryURL, c.repository, blob.Digest), name)
+	if err != nil {
+		return nil, err
+	}
+
+	return checkedAsset(filename, blob.Size, digest, "")
+}
@@ -0,0 +1,239 @@
+package version_test
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"runtime"
+	"strings"
+	"testing"
+
+	"github.com/dgmorales/go-cli-selfupdate/oci"
+	"github.com/dgmorales/go-cli-selfupdate/version"
+)
+
+const (
+	fakeRegistryUser     = "robot"
+	fakeRegistryPassword = "hunter2"
+	fakeRegistryToken    = "registry-token-not-really"
+	fakeOCIRepo          = fakeOrg + "/" + fakeRepo
+)
+
+// fakeRegistry is a local stand-in for an OCI registry, serving fakeOCIRepo
+type fakeRegistry struct {
+	tags []string
+
+	// if index is true, tags point to an image index with a manifest per platform,
+	// otherwise to a single manifest with a layer per platform
+	index bool
+
+	// if private is true, the registry requires a bearer token got from its /token
+	// endpoint with basic auth
+	private bool
+}
+
+func (f *fakeRegistry) layer(name string) map[string]interface{} {
+	return map[string]interface{}{
+		"mediaType":   "application/vnd.oci.image.layer.v1.tar+gzip",
+		"digest":      "sha256:" + fakeAssetSHA256(),
+		"size":        len(fakeAssetContent),
+		"annotations": map[string]string{"org.opencontainers.image.title": name},
+	}
+}
+
+func (f *fakeRegistry) manifest(tag string) map[string]interface{} {
+	if f.index {
+		manifests := []map[string]interface{}{}
+		for _, goos := range []string{"darwin", "linux", "windows"} {
+			manifests = append(manifests, map[string]interface{}{
+				"mediaType": "application/vnd.oci.image.manifest.v1+json",
+				"digest":    "sha256:platform-" + goos,
+				"platform":  map[string]string{"os": goos, "architecture": runtime.GOARCH},
+			})
+		}
+		return map[string]interface{}{
+			"mediaType": "application/vnd.oci.image.index.v1+json",
+			"manifests": manifests,
+		}
+	}
+
+	layers := []map[string]interface{}{}
+	for _, goos := range []string{"darwin", "linux", "windows"} {
+		layers = append(layers, f.layer(fakeAssetName(tag, goos)))
+	}
+	return map[string]interface{}{
+		"mediaType": "application/vnd.oci.image.manifest.v1+json",
+		"layers":    layers,
+	}
+}
+
+func (f *fakeRegistry) start(t *testing.T) *httptest.Server {
+	t.Helper()
+
+	var srv *httptest.Server
+	srv = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/token" {
+			user, pass, _ := r.BasicAuth()
+			if user != fakeRegistryUser || pass != fakeRegistryPassword {
+				w.WriteHeader(http.StatusUnauthorized)
+				return
+			}
+			json.NewEncoder(w).Encode(map[string]string{"token": fakeRegistryToken})
+			return
+		}
+
+		if f.private && r.Header.Get("Authorization") != "Bearer "+fakeRegistryToken {
+			w.Header().Set("WWW-Authenticate",
+				fmt.Sprintf(`Bearer realm="%s/token",service="fake",scope="repository:%s:pull"`, srv.URL, fakeOCIRepo))
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+
+		prefix := "/v2/" + fakeOCIRepo + "/"
+		if !strings.HasPrefix(r.URL.Path, prefix) {
+			http.NotFound(w, r)
+			return
+		}
+		p := strings.TrimPrefix(r.URL.Path, prefix)
+
+		switch {
+		case p == "tags/list":
+			// paginate with one tag per page, as registries do with ?n=1
+			i := 0
+			for i < len(f.tags) && r.URL.Query().Get("last") != "" && f.tags[i] != r.URL.Query().Get("last") {
+				i++
+			}
+			if r.URL.Query().Get("last") != "" {
+				i++
+			}
+			page := []string{}
+			if i < len(f.tags) {
+				page = append(page, f.tags[i])
+			}
+			if i+1 < len(f.tags) {
+				w.Header().Set("Link", fmt.Sprintf(`<%stags/list?n=1&last=%s>; rel="next"`, prefix, f.tags[i]))
+			}
+			json.NewEncoder(w).Encode(map[string]interface{}{"name": fakeOCIRepo, "tags": page})
+
+		case strings.HasPrefix(p, "manifests/sha256:platform-"):
+			goos := strings.TrimPrefix(p, "manifests/sha256:platform-")
+			json.NewEncoder(w).Encode(map[string]interface{}{
+				"mediaType": "application/vnd.oci.image.manifest.v1+json",
+				"layers":    []interface{}{f.layer(fmt.Sprintf("test-%s.tar.gz", goos))},
+			})
+
+		case strings.HasPrefix(p, "manifests/"):
+			json.NewEncoder(w).Encode(f.manifest(strings.TrimPrefix(p, "manifests/")))
+
+		case p == "blobs/sha256:"+fakeAssetSHA256():
+			fmt.Fprint(w, fakeAssetContent)
+
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	t.Cleanup(srv.Close)
+
+	return srv
+}
+
+func TestOCICheckerImplementsChecker(t *testing.T) {
+	var i interface{} = new(version.OCIChecker)
+	if _, ok := i.(version.Checker); !ok {
+		t.Fatalf("expected %T to implement version.Checker", i)
+	}
+}
+
+func TestNewOCIChecker(t *testing.T) {
+	testCases := []struct {
+		desc string
+		tags []string
+		spec versionsCaseSpec
+	}{
+		{
+			desc: "PicksHighestVersionTagAcrossPages",
+			tags: []string{"latest", "v1.0.0", "v2.10.0", "v2.9.0", "v3.0.0-rc.1"},
+			spec: versionsCaseSpec{min: "1.0.0", cur: "v2.0.0", latest: "v2.10.0"},
+		},
+		{
+			desc: "LatestIsUnknownWithoutVersionTags",
+			tags: []string{"latest"},
+			spec: versionsCaseSpec{min: "1.0.0", cur: "v2.0.0", latest: ""},
+		},
+	}
+	for _, tC := range testCases {
+		t.Run(tC.desc, func(t *testing.T) {
+			srv := (&fakeRegistry{tags: tC.tags}).start(t)
+
+			oc, err := version.NewOCIChecker(context.Background(), srv.Client(), srv.URL, fakeOCIRepo, version.Policy{MinimalRequired: tC.spec.min}, tC.spec.cur)
+			if err != nil {
+				t.Fatalf("expected nil error, got %s", err)
+			}
+
+			verifyExpectedVersions(t, oc, tC.spec)
+		})
+	}
+}
+
+func TestOCICheckerDownloadLatest(t *testing.T) {
+	testCases := []struct {
+		desc     string
+		registry fakeRegistry
+	}{
+		{
+			desc:     "WorksWithLayerPerPlatform",
+			registry: fakeRegistry{tags: []string{"v3.0.0"}},
+		},
+		{
+			desc:     "WorksWithImageIndex",
+			registry: fakeRegistry{tags: []string{"v3.0.0"}, index: true},
+		},
+		{
+			desc:     "WorksWithPrivateRegistry",
+			registry: fakeRegistry{tags: []string{"v3.0.0"}, private: true},
+		},
+	}
+	for _, tC := range testCases {
+		t.Run(tC.desc, func(t *testing.T) {
+			srv := tC.registry.start(t)
+
+			t.Setenv("OCI_REGISTRY_USERNAME", fakeRegistryUser)
+			t.Setenv("OCI_REGISTRY_PASSWORD", fakeRegistryPassword)
+			client, err := oci.NewClient(srv.URL)
+			if err != nil {
+				t.Fatalf("expected nil error creating client, got %s", err)
+			}
+
+			oc, err := version.NewOCIChecker(context.Background(), client, srv.URL, fakeOCIRepo, version.Policy{MinimalRequired: "v1"}, "v2")
+			if err != nil {
+				t.Fatalf("expected nil error, got %s", err)
+			}
+
+			if ans := oc.Check(); ans != version.CanUpdate {
+				t.Fatalf("expected '%d/%s', got '%d/%s'", version.CanUpdate, assertStr(version.CanUpdate), ans, assertStr(ans))
+			}
+
+			asset, err := oc.DownloadLatest(context.Background())
+			if err != nil {
+				t.Fatalf("expected nil error on download, got %s", err)
+			}
+
+			verifyDownloadedFile(t, asset)
+		})
+	}
+}
+
+func TestOCICheckerLatestIsUnknownForPrivateRegistryWithoutCredentials(t *testing.T) {
+	srv := (&fakeRegistry{tags: []string{"v3.0.0"}, private: true}).start(t)
+
+	oc, err := version.NewOCIChecker(context.Background(), srv.Client(), srv.URL, fakeOCIRepo, version.Policy{MinimalRequired: "v1"}, "v2")
+	if err != nil {
+		t.Fatalf("expected nil error, got %s", err)
+	}
+
+	if ans := oc.Check(); ans != version.IsUnknown {
+		t.Errorf("expected '%d/%s', got '%d/%s'", version.IsUnknown, assertStr(version.IsUnknown), ans, assertStr(ans))
+	}
+}
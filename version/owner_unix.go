@@ -0,0 +1,14 @@
+//go:build !windows
+
+package version
+
+import (
+	"os"
+	"syscall"
+)
+
+// ownedByUs tells if the file described by info is owned by the current user
+func ownedByUs(info os.FileInfo) bool {
+	st, ok := info.Sys().(*syscall.Stat_t)
+	return ok && int(st.Uid) == os.Getuid()
+}
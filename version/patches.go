@@ -0,0 +1,79 @@
+package version
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+
+	semver "github.com/hashicorp/go-version"
+)
+
+// ErrNoPatch means a release has no patch from the current version we can apply (and
+// verify), so the full release asset must be downloaded instead
+var ErrNoPatch = errors.New("no patch from the current version")
+
+// patchExt is the extension of bsdiff patches
+const patchExt = ".bsdiff"
+
+// patchInfix separates the binary name from the version a patch applies to
+const patchInfix = ".from-"
+
+// patchName returns the name the bsdiff patch from version from to the binary in the
+// release archive is published with: the binary name (see binaryName), .from-<from> and
+// .bsdiff, like go-cli-selfupdate-v0.4.0-linux-amd64.from-v0.3.0.bsdiff
+func patchName(archive string, from string) string {
+	return binaryName(archive) + patchInfix + from + patchExt
+}
+
+// patchFrom returns the version the release asset name is a patch from, if it is a patch
+// to the binary in the release archive
+func patchFrom(name string, archive string) (*semver.Version, bool) {
+	prefix := strings.ToLower(binaryName(archive) + patchInfix)
+	lower := strings.ToLower(name)
+	if !strings.HasPrefix(lower, prefix) || !strings.HasSuffix(lower, patchExt) {
+		return nil, false
+	}
+
+	from, err := semver.NewSemver(name[len(prefix) : len(name)-len(patchExt)])
+	if err != nil {
+		return nil, false
+	}
+
+	return from, true
+}
+
+// patchAsset returns the index of the patch from the current version to the binary in
+// the release archive among release asset names, or -1 if there is none
+func (s *versionSet) patchAsset(names []string, archive string) int {
+	if s.current == nil {
+		return -1
+	}
+
+	for i, name := range names {
+		if from, ok := patchFrom(name, archive); ok && from.Equal(s.current) {
+			return i
+		}
+	}
+
+	return -1
+}
+
+// isLatest tells if v is the latest version (that we already have asset information
+// about)
+func (s *versionSet) isLatest(v string) bool {
+	want, err := semver.NewSemver(v)
+	return err == nil && s.latest != nil && want.Equal(s.latest)
+}
+
+// patched takes a downloaded patch as such, if we can verify the binary it patches to
+func patched(a *Asset, err error) (*Asset, error) {
+	if err != nil {
+		return nil, err
+	}
+	if a.BinarySHA256 == nil {
+		return nil, fmt.Errorf("in Download: %w we can verify (no checksum of the patched binary)", ErrNoPatch)
+	}
+
+	a.Patch = true
+	return a, nil
+}
@@ -0,0 +1,66 @@
+package version
+
+import (
+	"fmt"
+	"time"
+)
+
+// Result is the outcome of a version check, for keeping it around (like for telling the
+// user about it later, without checking again)
+type Result struct {
+	Current   string    `json:"current"`
+	Minimal   string    `json:"minimal"`
+	Latest    string    `json:"latest"`
+	Assertion Assertion `json:"assertion"`
+	CheckedAt time.Time `json:"checkedAt"`
+
+	// Error is why the latest version is unknown, if it is
+	Error string `json:"error,omitempty"`
+
+	// Violated is the version constraint clause the current version violates, if any
+	Violated string `json:"violated,omitempty"`
+}
+
+// Snapshot checks c, returning the Result
+func Snapshot(c Checker) Result {
+	r := Result{
+		Current:   c.Current(),
+		Minimal:   c.Minimal(),
+		Latest:    c.Latest(),
+		Assertion: c.Check(),
+		CheckedAt: time.Now(),
+		Violated:  c.Violated(),
+	}
+	if err := c.LatestErr(); err != nil {
+		r.Error = err.Error()
+	}
+
+	return r
+}
+
+// String returns the name of a, as in its constant (like CanUpdate)
+func (a Assertion) String() string {
+	switch a {
+	case IsLatest:
+		return "IsLatest"
+	case CanUpdate:
+		return "CanUpdate"
+	case MustUpdate:
+		return "MustUpdate"
+	case IsBeyond:
+		return "IsBeyond"
+	case MustDowngrade:
+		return "MustDowngrade"
+	case IsRevoked:
+		return "IsRevoked"
+	case IsUnknown:
+		return "IsUnknown"
+	}
+
+	return fmt.Sprintf("Assertion(%d)", int(a))
+}
+
+// Blocking tells if a is a version state users can't keep running in
+func (a Assertion) Blocking() bool {
+	return a == MustUpdate || a == MustDowngrade || a == IsRevoked
+}
@@ -0,0 +1,66 @@
+package version
+
+import (
+	"crypto/sha256"
+	"encoding/binary"
+	"time"
+
+	semver "github.com/hashicorp/go-version"
+)
+
+// rolloutBuckets is how many buckets users are spread across for staged rollouts, so
+// rollout percentages may have two decimal places
+const rolloutBuckets = 10000
+
+// Rollout is a staged rollout of new releases: only a fraction of users (machines,
+// really) are offered the latest release at first. The rest keep being told they are at
+// the latest version, until the rollout reaches them.
+//
+// Users are bucketed by their ID and the release version, so each user stays in (or
+// out of) the rollout of a release as it grows, but is not always among the first ones
+// for every release.
+type Rollout struct {
+	// Percent is the fraction (0 to 100) of users offered the latest release, once the
+	// rollout is fully ramped up
+	Percent float64
+
+	// Start is when the rollout starts: no one is offered the latest release before
+	// it. Zero means it already started.
+	Start time.Time
+
+	// Ramp is how long after Start the rollout takes to grow linearly from 0 to Percent.
+	// Zero means it is at Percent right from Start. Ramp requires Start.
+	Ramp time.Duration
+
+	// ID identifies the user for bucketing. It must be the same on every run.
+	ID string
+}
+
+// percentAt returns the fraction of users offered the latest release at t
+func (r *Rollout) percentAt(t time.Time) float64 {
+	if r.Start.IsZero() {
+		return r.Percent
+	}
+	if t.Before(r.Start) {
+		return 0
+	}
+	if r.Ramp <= 0 || t.Sub(r.Start) >= r.Ramp {
+		return r.Percent
+	}
+
+	return r.Percent * float64(t.Sub(r.Start)) / float64(r.Ramp)
+}
+
+// bucket returns where (from 0 to 100, exclusive) the user falls in the rollout of the
+// release of version v
+func (r *Rollout) bucket(v *semver.Version) float64 {
+	sum := sha256.Sum256([]byte(r.ID + "\x00" + v.String()))
+	n := binary.BigEndian.Uint64(sum[:8]) % rolloutBuckets
+
+	return float64(n) * 100 / rolloutBuckets
+}
+
+// includes tells if the user is offered the release of version v at t
+func (r *Rollout) includes(v *semver.Version, t time.Time) bool {
+	return r.bucket(v) < r.percentAt(t)
+}
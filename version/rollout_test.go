@@ -0,0 +1,121 @@
+package version_test
+
+import (
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/dgmorales/go-cli-selfupdate/version"
+)
+
+func TestRolloutHoldsBackLatestRelease(t *testing.T) {
+	dir := newReleaseDir(t, "", "v2.0.0", "v3.0.0")
+
+	testCases := []struct {
+		desc     string
+		min      string
+		rollout  *version.Rollout
+		vAssert  version.Assertion
+		heldBack bool
+	}{
+		{
+			desc:    "OffersLatestWithoutRollout",
+			vAssert: version.CanUpdate,
+		},
+		{
+			desc:    "OffersLatestWhenFullyRolledOut",
+			rollout: &version.Rollout{Percent: 100, ID: "some-machine"},
+			vAssert: version.CanUpdate,
+		},
+		{
+			desc:     "HoldsBackLatestWhenPaused",
+			rollout:  &version.Rollout{Percent: 0, ID: "some-machine"},
+			vAssert:  version.IsLatest,
+			heldBack: true,
+		},
+		{
+			desc:     "HoldsBackLatestBeforeStart",
+			rollout:  &version.Rollout{Percent: 100, Start: time.Now().Add(time.Hour), ID: "some-machine"},
+			vAssert:  version.IsLatest,
+			heldBack: true,
+		},
+		{
+			desc:    "OffersLatestAfterRamp",
+			rollout: &version.Rollout{Percent: 100, Start: time.Now().Add(-2 * time.Hour), Ramp: time.Hour, ID: "some-machine"},
+			vAssert: version.CanUpdate,
+		},
+		{
+			desc:    "NeverHoldsBackRequiredUpdates",
+			min:     "v3.0.0",
+			rollout: &version.Rollout{Percent: 0, ID: "some-machine"},
+			vAssert: version.MustUpdate,
+		},
+	}
+	for _, tC := range testCases {
+		t.Run(tC.desc, func(t *testing.T) {
+			ldc, err := version.NewLocalDirChecker(dir, version.Policy{MinimalRequired: tC.min, Rollout: tC.rollout}, "v2.0.0")
+			if err != nil {
+				t.Fatalf("expected nil error, got %s", err)
+			}
+
+			if ans := ldc.Check(); ans != tC.vAssert {
+				t.Errorf("expected '%d/%s', got '%d/%s'", tC.vAssert, assertStr(tC.vAssert), ans, assertStr(ans))
+			}
+			if ldc.HeldBack() != tC.heldBack {
+				t.Errorf("expected held back to be %t, got %t", tC.heldBack, ldc.HeldBack())
+			}
+		})
+	}
+}
+
+func TestRolloutBucketsMachines(t *testing.T) {
+	dir := newReleaseDir(t, "", "v2.0.0", "v3.0.0")
+
+	testCases := []struct {
+		desc    string
+		rollout version.Rollout
+		expPct  int
+	}{
+		{
+			desc:    "OffersLatestToPercent",
+			rollout: version.Rollout{Percent: 25},
+			expPct:  25,
+		},
+		{
+			desc:    "RampsUpLinearly",
+			rollout: version.Rollout{Percent: 80, Start: time.Now().Add(-time.Hour), Ramp: 2 * time.Hour},
+			expPct:  40,
+		},
+	}
+	for _, tC := range testCases {
+		t.Run(tC.desc, func(t *testing.T) {
+			const machines = 2000
+
+			offered := 0
+			for i := 0; i < machines; i++ {
+				r := tC.rollout
+				r.ID = fmt.Sprintf("machine-%d", i)
+
+				ldc, err := version.NewLocalDirChecker(dir, version.Policy{Rollout: &r}, "v2.0.0")
+				if err != nil {
+					t.Fatalf("expected nil error, got %s", err)
+				}
+
+				ans := ldc.Check()
+				if ans == version.CanUpdate {
+					offered++
+				}
+
+				// the same machine always gets the same answer
+				if again := ldc.Check(); again != ans {
+					t.Fatalf("expected %s to get the same answer every time, got %d and %d", r.ID, ans, again)
+				}
+			}
+
+			pct := offered * 100 / machines
+			if pct < tC.expPct-5 || pct > tC.expPct+5 {
+				t.Errorf("expected about %d%% of machines to be offered the latest release, got %d%%", tC.expPct, pct)
+			}
+		})
+	}
+}
@@ -0,0 +1,25 @@
+package version
+
+import "strings"
+
+// signatureExt is the extension of minisign signatures
+const signatureExt = ".minisig"
+
+// signatureName returns the name the minisign signature of the binary in the release
+// archive name is published with: the binary name (see binaryName) plus .minisig
+func signatureName(archive string) string {
+	return binaryName(archive) + signatureExt
+}
+
+// signatureAsset returns the index of the signature of the binary in the release
+// archive among release asset names, or -1 if there is none
+func signatureAsset(names []string, archive string) int {
+	want := signatureName(archive)
+	for i, name := range names {
+		if strings.EqualFold(name, want) {
+			return i
+		}
+	}
+
+	return -1
+}
@@ -5,6 +5,7 @@ Copyright © 2022 Diego Morales <dgmorales@gmail.com>
 package version
 
 import (
+	"context"
 	_ "embed"
 )
 
@@ -20,17 +21,86 @@ type Assertion int
 // Avoid using 1 and 2 that are frequently used as more general error exit codes for
 // commands: https://tldp.org/LDP/abs/html/exitcodes.html
 const (
-	IsLatest   Assertion = 0
-	CanUpdate  Assertion = 10
-	MustUpdate Assertion = 20
-	IsBeyond   Assertion = 30  // Beyond current version: development version
-	IsUnknown  Assertion = 60
+	IsLatest      Assertion = 0
+	CanUpdate     Assertion = 10
+	MustUpdate    Assertion = 20
+	IsBeyond      Assertion = 30 // Beyond current version: development version
+	MustDowngrade Assertion = 40 // Above maximal allowed (or pinned) version
+	IsRevoked     Assertion = 50 // Current version was revoked, e.g. for a security bug
+	IsUnknown     Assertion = 60
 )
 
+// Checker tells our version state, and downloads releases.
+//
+// Implementations find out the latest version when created, so Check never blocks.
+// Downloads are canceled when their ctx is done, keeping what was downloaded so far for
+// resuming them later.
 type Checker interface {
 	Minimal() string
 	Current() string
 	Latest() string
+
+	// LatestErr returns why the latest version is unknown, if it is (like a rate limit
+	// error)
+	LatestErr() error
+
+	// LatestRelease describes the release of the latest version
+	LatestRelease() ReleaseInfo
+
+	// Violated returns the clause of the version constraint (see Policy.Constraint) the
+	// current version violates, if any
+	Violated() string
+
+	// Refused tells why the version policy refuses version v, for installing it on
+	// request, or returns blank if it does not
+	Refused(v string) string
+
+	// HeldBack tells if a staged rollout holds the latest release back from us, for now
+	// (see Policy.Rollout)
+	HeldBack() bool
+
 	Check() (Assertion)
-	DownloadLatest() (filename string, err error)
+	DownloadLatest(ctx context.Context) (*Asset, error)
+	DownloadVersion(ctx context.Context, v string) (*Asset, error)
+
+	// DownloadPatch downloads a patch from the current version to version v, if its
+	// release has one. It returns an error matching ErrNoPatch if it does not.
+	DownloadPatch(ctx context.Context, v string) (*Asset, error)
+
+	// SetProgress makes downloads report their progress to p
+	SetProgress(p Progress)
+}
+
+// ReleaseInfo describes a release, as found in the release source
+type ReleaseInfo struct {
+	// Asset is the name of the release asset for our platform, or blank if there is
+	// none (or it is unknown)
+	Asset string
+
+	// URL is where the release is published (like its web page), or blank if unknown
+	URL string
+}
+
+// Asset is a release asset downloaded to a local file, already verified against the
+// checksums published for it, if any. Its signature, if any, is downloaded too, but
+// verified only when applied (see selfupdate.Apply).
+type Asset struct {
+	// Filename is where the asset (an archive with the binary, or a patch) was
+	// downloaded to
+	Filename string
+
+	// SHA256 is the published checksum of the archive, or nil if there is none
+	SHA256 []byte
+
+	// BinarySHA256 is the published checksum of the binary in the archive, or nil if
+	// there is none
+	BinarySHA256 []byte
+
+	// Signature is the file with the published minisign signature of the binary in the
+	// archive, or blank if there is none
+	Signature string
+
+	// Patch means the asset is a bsdiff patch from the current binary to the release
+	// binary, instead of an archive. BinarySHA256 is always set for patches.
+	Patch bool
 }
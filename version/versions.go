@@ -0,0 +1,340 @@
+package version
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	semver "github.com/hashicorp/go-version"
+)
+
+// Policy is the server side policy on which versions users should be running, and how
+// to find their release assets
+type Policy struct {
+	// MinimalRequired is the minimal version users must be running. Blank means there
+	// is none.
+	MinimalRequired string
+
+	// MaximalAllowed is the maximal version users may be running, for when a bad
+	// release is out. Users above it must downgrade, and are never offered releases
+	// above it. Blank means there is none.
+	MaximalAllowed string
+
+	// Pinned is the exact version users must be running, no matter which is the latest
+	// release. Blank means there is none.
+	Pinned string
+
+	// Revoked are versions users must not be running, even if above the minimal
+	// required, like releases with a security bug. Each one is either an exact version
+	// (2.3.1) or a constraint (>= 2.3.0, < 2.3.2).
+	//
+	// Note that, as with any go-version constraint, a range only matches prereleases if
+	// its bounds are prereleases of the same version.
+	Revoked []string
+
+	// AssetPattern is the release asset name pattern, a regular expression where
+	// {name}, {version}, {os}, {arch} and {ext} stand for any name, the release version
+	// (with or without the v prefix), our GOOS, our GOARCH (or a usual alias, like
+	// x86_64 for amd64) and an archive extension we know how to uncompress. Matching
+	// ignores case. Blank means DefaultAssetPattern.
+	//
+	// Exactly one asset of a release must match it.
+	AssetPattern string
+
+	// Constraint is a version constraint users must be running a version within, like
+	// ">= 0.4.0, != 0.5.1, < 2.0.0". Versions below a clause must update, versions
+	// above it must downgrade, and versions excluded with != are revoked. Blank means
+	// there is none.
+	//
+	// Releases outside it are never offered: if the latest release is, users are offered
+	// the newest release below it within the constraint instead.
+	Constraint string
+
+	// Rollout stages the rollout of the latest release, offering it to a fraction of
+	// users only. Nil means everyone is offered it right away.
+	Rollout *Rollout
+}
+
+// versionSet holds the versions a Checker reasons about, and the policy around them.
+//
+// It implements the Minimal, Current, Latest and Check methods of the Checker interface,
+// so every Checker implementation can embed it and share the same assertion logic. The
+// implementation is only responsible for discovering the latest version.
+//
+// Here, latest means the version users should be running: the latest release, unless
+// the policy pins or caps it (see limit), or does not allow it, like when it is revoked
+// (see fallback).
+type versionSet struct {
+	minimal *semver.Version
+	maximal *semver.Version
+	pinned  *semver.Version
+	current *semver.Version
+	latest  *semver.Version
+	revoked []semver.Constraints
+	rollout *Rollout
+
+	// constraint is the version constraint from policy, if any
+	constraint semver.Constraints
+
+	// latestErr is why latest is unknown, if it is
+	latestErr error
+
+	// release describes the release of latest, as far as the implementation knows
+	release ReleaseInfo
+
+	assetPattern string
+}
+
+// newVersionSet parses the current version and the versions in policy.
+func newVersionSet(policy Policy, current string) (versionSet, error) {
+	var err error
+	vs := versionSet{}
+
+	vs.current, err = semver.NewSemver(current)
+	if err != nil {
+		return vs, err
+	}
+
+	for _, pv := range []struct {
+		v   **semver.Version
+		str string
+	}{
+		{&vs.minimal, policy.MinimalRequired},
+		{&vs.maximal, policy.MaximalAllowed},
+		{&vs.pinned, policy.Pinned},
+	} {
+		if strings.TrimSpace(pv.str) != "" {
+			*pv.v, err = semver.NewSemver(pv.str)
+			if err != nil {
+				return vs, err
+			}
+		}
+	}
+
+	for _, r := range policy.Revoked {
+		if strings.TrimSpace(r) == "" {
+			continue
+		}
+		c, err := semver.NewConstraint(r)
+		if err != nil {
+			return vs, fmt.Errorf("invalid revoked version %q: %w", r, err)
+		}
+		vs.revoked = append(vs.revoked, c)
+	}
+
+	if strings.TrimSpace(policy.Constraint) != "" {
+		vs.constraint, err = semver.NewConstraint(policy.Constraint)
+		if err != nil {
+			return vs, fmt.Errorf("invalid version constraint %q: %w", policy.Constraint, err)
+		}
+	}
+
+	_, err = assetRegexp(policy.AssetPattern, nil)
+	if err != nil {
+		return vs, err
+	}
+	vs.assetPattern = policy.AssetPattern
+	vs.rollout = policy.Rollout
+
+	return vs, nil
+}
+
+// isRevoked tells if version v is revoked by policy
+func (s *versionSet) isRevoked(v *semver.Version) bool {
+	for _, c := range s.revoked {
+		if c.Check(v) {
+			return true
+		}
+	}
+	return false
+}
+
+// errNoAllowedRelease means policy allows none of the releases users could be offered
+var errNoAllowedRelease = errors.New("no release allowed by policy")
+
+// allowed tells if policy lets users be offered version v: it is not revoked, and it is
+// within the version constraint, if any
+func (s *versionSet) allowed(v *semver.Version) bool {
+	return !s.isRevoked(v) && (s.constraint == nil || s.constraint.Check(v))
+}
+
+// fallback returns the index of the newest of versions below v that policy allows, for
+// when it does not allow v, or -1 if there is none. Nil versions are skipped.
+func (s *versionSet) fallback(v *semver.Version, versions []*semver.Version) int {
+	found := -1
+	for i, fv := range versions {
+		if fv == nil || !fv.LessThan(v) || !s.allowed(fv) {
+			continue
+		}
+		if found < 0 || fv.GreaterThan(versions[found]) {
+			found = i
+		}
+	}
+
+	return found
+}
+
+// limit returns the version users should be running instead of latest (which may be nil
+// if unknown), if there is one: the pinned version, or the maximal allowed version when
+// latest is above it.
+//
+// It returns nil when latest is fine. Either way, a version policy does not allow (see
+// allowed) must be replaced by a fallback one.
+func (s *versionSet) limit(latest *semver.Version) *semver.Version {
+	if s.pinned != nil {
+		return s.pinned
+	}
+
+	if s.maximal != nil && latest != nil && latest.GreaterThan(s.maximal) {
+		return s.maximal
+	}
+
+	return nil
+}
+
+// Refused tells why policy refuses version v, for installing it on request (like "it
+// was revoked"), or returns blank if it does not
+func (s *versionSet) Refused(v string) string {
+	want, err := semver.NewSemver(v)
+	if err != nil {
+		return err.Error()
+	}
+
+	switch {
+	case s.pinned != nil && !want.Equal(s.pinned):
+		return fmt.Sprintf("version %s is pinned", s.pinned)
+	case s.minimal != nil && want.LessThan(s.minimal):
+		return fmt.Sprintf("it is bellow the minimal required version (%s)", s.minimal)
+	case s.maximal != nil && want.GreaterThan(s.maximal):
+		return fmt.Sprintf("it is above the maximal allowed version (%s)", s.maximal)
+	case s.isRevoked(want):
+		return "it was revoked"
+	}
+
+	if clause, _ := s.violationOf(want); clause != nil {
+		return fmt.Sprintf("it violates the version constraint %q", strings.TrimSpace(clause.String()))
+	}
+
+	return ""
+}
+
+// releaseTags returns the tag names a release of version v may have, with and without
+// the usual v prefix
+func releaseTags(v *semver.Version) []string {
+	orig := strings.TrimPrefix(v.Original(), "v")
+	return []string{"v" + orig, orig}
+}
+
+// setLatest parses and saves the latest version, usually taken from a release tag
+func (s *versionSet) setLatest(latest string) (err error) {
+	s.latest, err = semver.NewSemver(latest)
+	return err
+}
+
+// Current returns current version as string
+func (s *versionSet) Current() string {
+	if s == nil || s.current == nil {
+		return ""
+	}
+	return s.current.String()
+}
+
+// Minimal returns minimal required version as string
+func (s *versionSet) Minimal() string {
+	if s == nil || s.minimal == nil {
+		return ""
+	}
+	return s.minimal.String()
+}
+
+// Latest returns latest version as string
+func (s *versionSet) Latest() string {
+	if s == nil || s.latest == nil {
+		return ""
+	}
+	return s.latest.String()
+}
+
+// LatestErr returns why the latest version is unknown, if it is (and we know why)
+func (s *versionSet) LatestErr() error {
+	if s == nil {
+		return nil
+	}
+	return s.latestErr
+}
+
+// LatestRelease describes the release of the latest version
+func (s *versionSet) LatestRelease() ReleaseInfo {
+	if s == nil {
+		return ReleaseInfo{}
+	}
+	return s.release
+}
+
+// HeldBack tells if a staged rollout (see Policy.Rollout) holds the latest release back
+// from us, for now, making Check tell we are at the latest version. Required updates
+// (like when below the minimal required version) are never held back.
+func (s *versionSet) HeldBack() bool {
+	return s.Check() == IsLatest && s.latest != nil && s.current.LessThan(s.latest)
+}
+
+// inRollout tells if the staged rollout of the latest release, if any, includes us
+func (s *versionSet) inRollout() bool {
+	return s.rollout == nil || s.latest == nil || s.rollout.includes(s.latest, time.Now())
+}
+
+// Check discovers if the current version can or must be updated.
+//
+// More precisely, it checks in which version.Assertion case the current version falls
+// in.
+//
+// It tries to be resilient and always return the best assertion it can about the
+// current version. So for example, if the minimal version is unknown it will ignore
+// that check and check against the latest.
+//
+// If it cannot tell anything, it returns is IsUnknown.
+func (s *versionSet) Check() Assertion {
+	if s == nil || s.current == nil {
+		// return now otherwise we would panic trying the comparisons bellow
+		return IsUnknown
+	}
+
+	if s.isRevoked(s.current) {
+		return IsRevoked
+	}
+
+	if clause, violation := s.violation(); clause != nil {
+		return violation
+	}
+
+	if s.latest != nil && s.current.Equal(s.latest) {
+		return IsLatest
+	}
+
+	if (s.pinned != nil && s.current.GreaterThan(s.pinned)) ||
+		(s.maximal != nil && s.current.GreaterThan(s.maximal)) {
+		return MustDowngrade
+	}
+
+	if s.pinned != nil && s.current.LessThan(s.pinned) {
+		return MustUpdate
+	}
+
+	if s.minimal != nil && s.current.LessThan(s.minimal) {
+		return MustUpdate
+	}
+
+	if s.latest != nil && s.current.LessThan(s.latest) {
+		if !s.inRollout() {
+			return IsLatest
+		}
+		return CanUpdate
+	}
+
+	if s.latest != nil && s.current.GreaterThan(s.latest) {
+		return IsBeyond
+	}
+
+	return IsUnknown
+}
@@ -0,0 +1,77 @@
+package version_test
+
+import (
+	"testing"
+
+	"github.com/dgmorales/go-cli-selfupdate/version"
+)
+
+func TestRefusedFollowsPolicy(t *testing.T) {
+	testCases := []struct {
+		desc    string
+		policy  version.Policy
+		v       string
+		refused bool
+	}{
+		{
+			desc: "AllowsAnyVersionWithoutPolicy",
+			v:    "v0.1.0",
+		},
+		{
+			desc:    "RefusesBelowMinimalRequired",
+			policy:  version.Policy{MinimalRequired: "1.0.0"},
+			v:       "v0.9.0",
+			refused: true,
+		},
+		{
+			desc:    "RefusesAboveMaximalAllowed",
+			policy:  version.Policy{MaximalAllowed: "1.0.0"},
+			v:       "v1.0.1",
+			refused: true,
+		},
+		{
+			desc:    "RefusesOtherThanPinned",
+			policy:  version.Policy{Pinned: "1.0.0"},
+			v:       "v0.9.0",
+			refused: true,
+		},
+		{
+			desc:   "AllowsPinned",
+			policy: version.Policy{Pinned: "1.0.0"},
+			v:      "v1.0.0",
+		},
+		{
+			desc:    "RefusesRevoked",
+			policy:  version.Policy{Revoked: []string{">= 0.9.0, < 0.9.3"}},
+			v:       "v0.9.2",
+			refused: true,
+		},
+		{
+			desc:    "RefusesOutsideVersionConstraint",
+			policy:  version.Policy{Constraint: ">= 0.4.0, < 2.0.0"},
+			v:       "v2.1.0",
+			refused: true,
+		},
+		{
+			desc:   "AllowsWithinPolicy",
+			policy: version.Policy{MinimalRequired: "0.5.0", MaximalAllowed: "1.0.0", Revoked: []string{"0.9.1"}, Constraint: "!= 0.8.0"},
+			v:      "v0.9.0",
+		},
+	}
+	for _, tC := range testCases {
+		t.Run(tC.desc, func(t *testing.T) {
+			ldc, err := version.NewLocalDirChecker(newReleaseDir(t, "", "v1.0.0"), tC.policy, "v1.0.0")
+			if err != nil {
+				t.Fatalf("expected nil error, got %s", err)
+			}
+
+			why := ldc.Refused(tC.v)
+			if tC.refused && why == "" {
+				t.Errorf("expected version %s to be refused, it was not", tC.v)
+			}
+			if !tC.refused && why != "" {
+				t.Errorf("expected version %s to be allowed, it was refused: %s", tC.v, why)
+			}
+		})
+	}
+}